@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"game-server/internal/backup"
+	"game-server/internal/config"
+)
+
+const verifyCommand = "verify"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		return
+	}
+
+	switch os.Args[1] {
+	case verifyCommand:
+		verify(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <command> [flags]\n\nCommands:\n  %s\tcheck a backup against its manifest\n", os.Args[0], verifyCommand)
+}
+
+func verify(args []string) {
+	flags := flag.NewFlagSet(verifyCommand, flag.ExitOnError)
+	game := flags.String("game", "", "game to verify the backup of")
+	date := flags.String("date", "", "date of the backup to verify, as YYYY-MM-DD, defaults to the latest")
+	flags.Parse(args)
+	if *game == "" {
+		flags.Usage()
+		return
+	}
+
+	var backupDate time.Time
+	if *date != "" {
+		var err error
+		if backupDate, err = time.Parse(backup.DateFormat, *date); err != nil {
+			panic(err)
+		}
+	}
+
+	cfg := config.New()
+	if err := cfg.Load(); err != nil {
+		panic(err)
+	}
+
+	err := backup.New(cfg).Verify(context.Background(), *game, backupDate)
+	var verifyErr backup.VerifyError
+	if errors.As(err, &verifyErr) {
+		fmt.Fprintf(os.Stderr, "%s backup from %s failed verification:\n", verifyErr.Game, verifyErr.Date)
+		for _, failure := range verifyErr.Failures {
+			fmt.Fprintf(os.Stderr, "  %s\n", failure)
+		}
+		os.Exit(1)
+	} else if err != nil {
+		panic(err)
+	}
+}
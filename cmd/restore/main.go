@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"game-server/internal/backup"
+	"game-server/internal/config"
+)
+
+var (
+	game = flag.String("game", "", "game to restore the save files of")
+	date = flag.String("date", "", "date of the backup to restore, as YYYY-MM-DD")
+)
+
+func main() {
+	flag.Parse()
+	if *game == "" || *date == "" {
+		flag.Usage()
+		return
+	}
+
+	backupDate, err := time.Parse(backup.DateFormat, *date)
+	if err != nil {
+		panic(err)
+	}
+
+	cfg := config.New()
+	if err := cfg.Load(); err != nil {
+		panic(err)
+	}
+
+	if err := backup.New(cfg).Restore(*game, backupDate); err != nil {
+		panic(err)
+	}
+}
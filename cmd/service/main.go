@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+
 	"game-server/internal/service"
 )
 
 func main() {
+	ctx, stop := service.SignalContext(context.Background())
+	defer stop()
+
 	s := service.New()
-	s.Run()
+	s.Run(ctx)
 }
@@ -5,11 +5,13 @@ go 1.19
 require (
 	github.com/aws/aws-lambda-go v1.35.0
 	github.com/aws/aws-sdk-go v1.44.162
+	github.com/bmatcuk/doublestar/v4 v4.6.1
 	github.com/bwmarrin/discordgo v0.26.1
 	github.com/google/gopacket v1.1.19
 	github.com/stretchr/testify v1.8.1
 	go.uber.org/multierr v1.6.0
 	go.uber.org/zap v1.24.0
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
 
 require (
@@ -0,0 +1,264 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"game-server/internal/config"
+	"game-server/pkg/aws/s3"
+)
+
+// Files of an archive backup, within its date folder
+const (
+	archiveName  = "backup.tar.gz"
+	manifestName = "manifest.json"
+)
+
+// Manifest lists the files in a backup, with the hash of each as it was uploaded
+type Manifest struct {
+	Format     string         `json:"format"`
+	Files      []ManifestFile `json:"files"`
+	Encrypted  bool           `json:"encrypted,omitempty"`
+	KeyID      string         `json:"keyId,omitempty"`      // Backup key the data keys are wrapped by
+	WrappedKey string         `json:"wrappedKey,omitempty"` // Data key of the backup, wrapped by the backup key
+}
+
+type ManifestFile struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	WrappedKey string `json:"wrappedKey,omitempty"` // Data key of a shared blob stored by an earlier backup
+}
+
+// fileKey gets the wrapped data key a file of the backup is sealed with
+func (m *Manifest) fileKey(file ManifestFile) string {
+	if file.WrappedKey != "" {
+		return file.WrappedKey
+	}
+	return m.WrappedKey
+}
+
+// setKey records the data key of the backup, if encrypted
+func (m *Manifest) setKey(s *sealer) {
+	if s == nil {
+		return
+	}
+	m.Encrypted = true
+	m.KeyID = s.keyID
+	m.WrappedKey = s.wrapped
+}
+
+// putArchive streams the save files into a single compressed archive in the folder, followed by its manifest
+func (c *Client) putArchive(folder string, saveFiles map[string]io.ReadSeekCloser, s *sealer) error {
+	r, w := io.Pipe()
+	manifestCh := make(chan *Manifest, 1)
+	go func() {
+		manifest, err := writeArchive(w, saveFiles)
+		w.CloseWithError(err)
+		manifestCh <- manifest
+	}()
+
+	err := c.upload(r, path.Join(folder, archiveName), s)
+	r.CloseWithError(err) // Unblock the archive if the upload stopped early
+	manifest := <-manifestCh
+	if err != nil {
+		return err
+	}
+	manifest.setKey(s)
+	return c.putManifest(folder, manifest)
+}
+
+// writeArchive writes the save files as a gzipped tarball, getting the manifest as it goes
+func writeArchive(w io.Writer, saveFiles map[string]io.ReadSeekCloser) (*Manifest, error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// Sort for a consistent archive
+	filePaths := sortedPaths(saveFiles)
+
+	manifest := &Manifest{
+		Format: config.BackupTarGz,
+		Files:  make([]ManifestFile, 0, len(filePaths)),
+	}
+	for _, filePath := range filePaths {
+		f := saveFiles[filePath]
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		name := manifestPath(filePath)
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     size,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(tw, io.TeeReader(f, hash)); err != nil {
+			return nil, fmt.Errorf("could not archive [%s]: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:   name,
+			Size:   size,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gw.Close()
+}
+
+func (c *Client) putManifest(folder string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return c.storage.Put(bytes.NewReader(data), c.bucket, path.Join(folder, manifestName))
+}
+
+// getManifest gets the manifest of the backup in the folder
+func (c *Client) getManifest(folder string) (*Manifest, error) {
+	body, err := c.storage.Get(c.bucket, path.Join(folder, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// getLayout gets the manifest of the backup in the folder from its keys, nil if the backup has none
+func (c *Client) getLayout(folder string, keys []string) (*Manifest, error) {
+	prefix := folder + s3.Delimiter
+	if !hasKey(keys, prefix+archiveName) && !hasKey(keys, prefix+manifestName) {
+		return nil, nil
+	}
+	return c.getManifest(folder)
+}
+
+// hashFile gets the manifest entry of a save file, leaving it ready to be read again
+func hashFile(filePath string, f io.ReadSeeker) (ManifestFile, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return ManifestFile{}, err
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ManifestFile{}, err
+	}
+	return ManifestFile{
+		Path:   manifestPath(filePath),
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// sortedPaths gets the paths of the save files in order
+func sortedPaths(saveFiles map[string]io.ReadSeekCloser) []string {
+	filePaths := make([]string, 0, len(saveFiles))
+	for filePath := range saveFiles {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+	return filePaths
+}
+
+// manifestPath gets the path of a save file as listed in a manifest, relative to the working directory
+func manifestPath(filePath string) string {
+	return strings.TrimPrefix(path.Clean(filePath), "/")
+}
+
+// extractArchive writes each file of the archive backup in the folder to the working directory,
+// checking it against the manifest
+func (c *Client) extractArchive(folder string, workingDir string, manifest *Manifest) error {
+	return c.readArchive(folder, manifest, func(file ManifestFile, r io.Reader) error {
+		filePath, err := restorePath(workingDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := writeFileChecked(filePath, r, file.SHA256); err != nil {
+			return fmt.Errorf("could not restore [%s]: %w", file.Path, err)
+		}
+		return nil
+	})
+}
+
+// readArchive calls fn with each file of the archive backup in the folder, checking it against the manifest
+// once fn has read it
+func (c *Client) readArchive(folder string, manifest *Manifest, fn func(file ManifestFile, r io.Reader) error) error {
+	body, err := c.storage.Get(c.bucket, path.Join(folder, archiveName))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	aead, err := c.dataKey(manifest, ManifestFile{})
+	if err != nil {
+		return err
+	}
+	archive, err := open(aead, body, manifest.Encrypted)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	gr, err := gzip.NewReader(archive)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+
+	expected := make(map[string]ManifestFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		expected[file.Path] = file
+	}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		file, ok := expected[header.Name]
+		if !ok {
+			return fmt.Errorf("file not in manifest: [%s]", header.Name)
+		}
+		delete(expected, header.Name)
+
+		hash := sha256.New()
+		if err := fn(file, io.TeeReader(tr, hash)); err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.SHA256 {
+			return fmt.Errorf("checksum mismatch for [%s]", file.Path)
+		}
+	}
+
+	if len(expected) > 0 {
+		return fmt.Errorf("%d files in manifest missing from archive", len(expected))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+)
+
+func Test_Client_ArchiveBackup(t *testing.T) {
+	saveData := map[string]string{
+		"world/level.dat":    "level data",
+		"world/region/r.0.0": "region data",
+	}
+
+	tests := []struct {
+		name   string
+		tamper bool
+		expErr string
+	}{
+		{
+			name: "Happy path",
+		},
+		{
+			name:   "Sad path - Checksum mismatch",
+			tamper: true,
+			expErr: "checksum mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			for filePath, data := range saveData {
+				filePath = filepath.Join(workingDir, filepath.FromSlash(filePath))
+				require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+				require.NoError(t, os.WriteFile(filePath, []byte(data), 0o644))
+			}
+			cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+				`[{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": "tar.gz"}]`, workingDir,
+			)))
+			gameCfg, _ := cfg.GetGameConfig("Game")
+
+			c := newTestClient(t, cfg)
+
+			// Backup as a single archive
+			date := time.Now().Format(DateFormat)
+			require.NoError(t, c.backupGame(context.Background(), gameCfg, time.Time{}))
+			folder, keys, _, err := c.getBackup("Game", date)
+			require.NoError(t, err)
+			assert.Equal(t, []string{path.Join(folder, archiveName), path.Join(folder, manifestName)}, keys)
+
+			manifest, err := c.getManifest(folder)
+			require.NoError(t, err)
+			require.Len(t, manifest.Files, len(saveData))
+			for _, file := range manifest.Files {
+				hash := sha256.Sum256([]byte(saveData[file.Path]))
+				assert.Equal(t, hex.EncodeToString(hash[:]), file.SHA256, file.Path)
+				assert.Equal(t, int64(len(saveData[file.Path])), file.Size, file.Path)
+			}
+
+			// Restore from the archive after the save has changed
+			if tt.tamper {
+				manifest.Files[0].SHA256 = hex.EncodeToString(make([]byte, sha256.Size))
+				require.NoError(t, c.putManifest(folder, manifest))
+			}
+			levelPath := filepath.Join(workingDir, "world", "level.dat")
+			require.NoError(t, os.WriteFile(levelPath, []byte("corrupted"), 0o644))
+
+			parsedDate, _ := time.Parse(DateFormat, date)
+			err = c.Restore("Game", parsedDate)
+
+			if tt.expErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			for filePath, expData := range saveData {
+				data, err := os.ReadFile(filepath.Join(workingDir, filepath.FromSlash(filePath)))
+				require.NoError(t, err)
+				assert.Equal(t, expData, string(data))
+			}
+		})
+	}
+}
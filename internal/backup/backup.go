@@ -1,48 +1,70 @@
 package backup
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"game-server/internal/config"
+	"game-server/internal/gamelock"
 	"game-server/pkg/aws/s3"
-	customerrors "game-server/pkg/errors"
 )
 
 const (
 	EnvGameSaveBucket = "GAME_SAVE_BUCKET"
 
-	loggerName = "save-backup"
+	// Backups are kept in a folder per date
+	DateFormat = "2006-01-02"
 
-	dateFolderFormat = "2006-01-02"
+	loggerName = "save-backup"
 )
 
 var (
-	Frequency = time.Hour * 24 // Default backup daily
+	Frequency = time.Hour * 24 // Backups known only by their date are due again after this
 )
 
+// Ensure Client implements ClientIFace
+var _ ClientIFace = (*Client)(nil)
+
+type ClientIFace interface {
+	DoBackup() error
+	ForceBackup(games ...string) error
+	BackupGame(ctx context.Context, game string) error
+	Restore(game string, date time.Time) error
+	Verify(ctx context.Context, game string, date time.Time) error
+}
+
+// Client is shared by the service, bot and backup scheduler, so it is never changed once started
 type Client struct {
 	cfg    *config.Config
 	logger *zap.Logger
 
-	s3Bucket string
-	s3Client s3.ClientIFace
+	startMu sync.Mutex
+	started bool
+
+	bucket  string // S3 bucket or root directory of the storage
+	storage Storage
+
+	key []byte // Master key for encrypted backups, nil if not encrypted
 }
 
 func New(cfg *config.Config) *Client {
 	return &Client{
-		cfg:      cfg,
-		logger:   cfg.Logger.Named(loggerName),
-		s3Client: s3.New(),
+		cfg:     cfg,
+		logger:  cfg.Logger.Named(loggerName),
+		storage: newStorage(cfg.Settings.BackupStorage),
 	}
 }
 
@@ -51,8 +73,8 @@ func (c *Client) DoBackup() error {
 		return err
 	}
 
-	// Get dates of last S3 backup for all games
-	s3Saves, err := c.getPrevSaveDates()
+	// Get when each game was last backed up
+	s3Saves, err := c.getLastBackups()
 	if err != nil {
 		return err
 	}
@@ -61,38 +83,101 @@ func (c *Client) DoBackup() error {
 	var multiErr error
 	for _, game := range c.cfg.GetGameNames() {
 		gameCfg, _ := c.cfg.GetGameConfig(game)
-		if err := c.backupGame(gameCfg, s3Saves[game]); err != nil {
+		if err := c.backupGame(context.Background(), gameCfg, s3Saves[game]); err != nil {
 			multiErr = multierr.Append(multiErr, err)
+			continue
+		}
+
+		// Only prune once the game has an up to date backup
+		if err := c.prune(gameCfg); err != nil {
+			multiErr = multierr.Append(multiErr, fmt.Errorf("could not prune %s backups: %w", gameCfg.Name, err))
 		}
 	}
 	return multiErr
 }
 
-func (c *Client) start() error {
-	// Connect AWS
-	if err := c.s3Client.Connect(); err != nil {
+// ForceBackup backs up each of the games in order without checking for previous backups, for when the host
+// is about to be lost
+func (c *Client) ForceBackup(games ...string) error {
+	if err := c.start(); err != nil {
 		return err
 	}
 
-	// Get env variables
-	bucket, ok := os.LookupEnv(EnvGameSaveBucket)
+	var multiErr error
+	for _, game := range games {
+		gameCfg, ok := c.cfg.GetGameConfig(game)
+		if !ok {
+			multiErr = multierr.Append(multiErr, fmt.Errorf("no configuration for game: [%s]", game))
+			continue
+		}
+		if err := c.backupGame(context.Background(), gameCfg, time.Time{}); err != nil {
+			multiErr = multierr.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
+
+// BackupGame backs up a single game without checking for previous backups, for when its save is known to be
+// newer, such as while it is running, abandoning the upload once the context is done
+func (c *Client) BackupGame(ctx context.Context, game string) error {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
 	if !ok {
-		return customerrors.MissingEnvErr{}
+		return fmt.Errorf("no configuration for game: [%s]", game)
+	}
+	if err := c.start(); err != nil {
+		return err
 	}
-	c.s3Bucket = bucket
 
+	if err := c.backupGame(ctx, gameCfg, time.Time{}); err != nil {
+		return err
+	}
+	if err := c.prune(gameCfg); err != nil {
+		return fmt.Errorf("could not prune %s backups: %w", gameCfg.Name, err)
+	}
+	return nil
+}
+
+// start connects the storage and loads the bucket and key on first use, retrying on later calls if it fails
+func (c *Client) start() error {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.started {
+		return nil
+	}
+
+	// Connect storage
+	if err := c.storage.Connect(); err != nil {
+		return err
+	}
+
+	bucket, err := getBucket(c.cfg.Settings.BackupStorage)
+	if err != nil {
+		return err
+	}
+	c.bucket = bucket
+
+	// Get key to encrypt backups with, if any
+	key, err := loadKey()
+	if err != nil {
+		return err
+	}
+	c.key = key
+
+	c.started = true
 	return nil
 }
 
-func (c *Client) getPrevSaveDates() (map[string]time.Time, error) {
+// getLastBackups gets when each game was last completely backed up, which its save files must be modified after
+// to need backing up again
+func (c *Client) getLastBackups() (map[string]time.Time, error) {
 	// Get all folders in the game save bucket
-	saveFolders, err := c.s3Client.GetFolders(c.s3Bucket, 2)
+	saveFolders, err := c.storage.GetFolders(c.bucket, 2)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build map of most recent save dates for each game
-	lastSaveDates := make(map[string]time.Time)
+	// Build map of the dates backed up for each game
+	saveDates := make(map[string][]string)
 	for _, saveFolder := range saveFolders {
 		folders := strings.Split(saveFolder, s3.Delimiter)
 
@@ -100,36 +185,37 @@ func (c *Client) getPrevSaveDates() (map[string]time.Time, error) {
 		gameName := folders[0]
 		if _, ok := c.cfg.GetGameConfig(gameName); ok && len(folders) > 1 {
 			datedFolder := folders[1]
-
-			// Parse folder name into date
-			saveDate, err := time.Parse(dateFolderFormat, datedFolder)
-			if err != nil {
-				continue
+			if _, err := time.Parse(DateFormat, datedFolder); err == nil {
+				saveDates[gameName] = append(saveDates[gameName], datedFolder)
 			}
+		}
+	}
 
-			// Add if most recent
-			if mostRecent, ok := lastSaveDates[gameName]; ok {
-				if mostRecent.Before(saveDate) {
-					lastSaveDates[gameName] = saveDate
-				}
-			} else {
-				lastSaveDates[gameName] = saveDate
+	// Most recent date with a complete backup, ignoring any runs that failed
+	lastBackups := make(map[string]time.Time)
+	for gameName, dates := range saveDates {
+		sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+		for _, date := range dates {
+			lastBackup, ok, err := c.lastBackupOn(gameName, date)
+			if err != nil {
+				return nil, err
+			} else if ok {
+				lastBackups[gameName] = lastBackup
+				break
 			}
 		}
 	}
-
-	return lastSaveDates, nil
+	return lastBackups, nil
 }
 
-func (c *Client) backupGame(gameCfg *config.GameConfig, lastSave time.Time) error {
-	saveFiles, lastMod, err := getSaveFiles(gameCfg)
+func (c *Client) backupGame(ctx context.Context, gameCfg *config.GameConfig, lastSave time.Time) error {
+	filePaths, lastMod, err := findSaveFiles(c.logger, gameCfg)
 	if err != nil {
 		return fmt.Errorf("could not get %s save files: %w", gameCfg.Name, err)
 	}
-	defer closeSaveFiles(saveFiles)
 
-	// Skip game if last backup was recent enough
-	if lastMod.Before(lastSave.Add(Frequency)) {
+	// Skip game if not modified since its last backup
+	if lastMod.Before(lastSave) {
 		c.logger.Info("no backup required", zap.String("game", gameCfg.Name))
 		return nil
 	}
@@ -141,69 +227,179 @@ func (c *Client) backupGame(gameCfg *config.GameConfig, lastSave time.Time) erro
 		zap.Time("modified", lastMod),
 	)
 
-	// Add each file to S3
+	// Upload a copy of the save files, since the game may still be writing them
+	started := time.Now()
+	snap, err := takeSnapshot(filePaths)
+	if err != nil {
+		return fmt.Errorf("could not snapshot %s save files: %w", gameCfg.Name, err)
+	}
+	defer snap.Close()
+
+	// Stop reading the save files once cancelled, which ends their upload
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	saveFiles := make(map[string]io.ReadSeekCloser, len(snap.files))
+	for saveFilePath, f := range snap.files {
+		saveFiles[saveFilePath] = cancelableFile{ReadSeekCloser: f, ctx: ctx}
+	}
+
+	// Each backup is encrypted with its own data key
+	s, err := newSealer(c.key)
+	if err != nil {
+		return fmt.Errorf("could not create %s backup key: %w", gameCfg.Name, err)
+	}
+
+	// Add all files as a single archive, or only those changed since any backup
+	folder := runFolder(gameCfg.Name, started)
+	switch gameCfg.BackupFormat {
+	case config.BackupTarGz:
+		return c.putArchive(folder, saveFiles, s)
+	case config.BackupIncremental:
+		return c.putIncremental(gameCfg.Name, folder, saveFiles, s)
+	}
+	return c.putFiles(folder, saveFiles, s)
+}
+
+// putFiles stores each save file in the folder, followed by a manifest of them
+func (c *Client) putFiles(folder string, saveFiles map[string]io.ReadSeekCloser, s *sealer) error {
+	manifest := &Manifest{
+		Format: config.BackupFiles,
+		Files:  make([]ManifestFile, 0, len(saveFiles)),
+	}
+	manifest.setKey(s)
+
 	var multiErr error
-	dateFolder := time.Now().Format(dateFolderFormat)
-	for filePath, saveFile := range saveFiles {
-		key := path.Join(gameCfg.Name, dateFolder, filePath)
-		if err := c.s3Client.Put(saveFile, c.s3Bucket, key); err != nil {
+	for _, filePath := range sortedPaths(saveFiles) {
+		f := saveFiles[filePath]
+		file, err := hashFile(filePath, f)
+		if err != nil {
 			multiErr = multierr.Append(multiErr, err)
+			continue
 		}
+		if err := c.put(f, path.Join(folder, file.Path), s); err != nil {
+			multiErr = multierr.Append(multiErr, err)
+			continue
+		}
+		manifest.Files = append(manifest.Files, file)
 	}
-	return multiErr
+
+	// Only a complete backup gets a manifest
+	if multiErr != nil {
+		return multiErr
+	}
+	return c.putManifest(folder, manifest)
 }
 
-func getSaveFiles(cfg *config.GameConfig) (saveFiles map[string]io.ReadSeekCloser, lastModified time.Time, err error) {
-	saveFiles = make(map[string]io.ReadSeekCloser)
+// put stores a file, encrypting it if there is a sealer
+func (c *Client) put(file io.ReadSeeker, key string, s *sealer) error {
+	if s == nil {
+		return c.storage.Put(file, c.bucket, key)
+	}
+	return c.upload(file, key, s)
+}
 
-	// If failure, ensure all files are closed
-	defer func() {
-		if err != nil {
-			closeSaveFiles(saveFiles)
-			saveFiles = nil
-		}
-	}()
+// upload streams a file to storage, encrypting if there is a sealer
+func (c *Client) upload(body io.Reader, key string, s *sealer) error {
+	if s == nil {
+		return c.storage.Upload(body, c.bucket, key)
+	}
+
+	sealed := s.seal(body)
+	err := c.storage.Upload(sealed, c.bucket, key)
+	sealed.CloseWithError(err) // Unblock the encryption if the upload stopped early
+	return err
+}
+
+// findSaveFiles gets the path of each save file keyed by its path within the backup,
+// along with when any of them was last modified, failing if a save file path does not exist
+func findSaveFiles(logger *zap.Logger, cfg *config.GameConfig) (filePaths map[string]string, lastModified time.Time, err error) {
+	return matchSaveFiles(logger, cfg, doublestar.WithFailOnPatternNotExist())
+}
+
+// matchSaveFiles gets the path of each save file keyed by its path within the backup,
+// along with when any of them was last modified
+func matchSaveFiles(
+	logger *zap.Logger,
+	cfg *config.GameConfig,
+	opts ...doublestar.GlobOption,
+) (filePaths map[string]string, lastModified time.Time, err error) {
+	filePaths = make(map[string]string)
+	workingDir := cfg.WorkingDir
+	if workingDir == "" {
+		workingDir = "."
+	}
 
 	// Handler for each save file
 	var handler fs.WalkDirFunc = func(filePath string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
+		if err != nil {
 			return err
 		}
 
-		f, err := os.Open(filePath)
+		// Leave out excluded files, and everything within excluded directories or moved aside by a restore
+		relPath, err := filepath.Rel(workingDir, filePath)
 		if err != nil {
 			return err
 		}
+		if isExcluded(cfg.Exclude, filepath.ToSlash(relPath)) || isAside(filepath.ToSlash(relPath)) || gamelock.IsLockFile(relPath) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		} else if d.IsDir() {
+			return nil
+		}
 
 		// Get last modified time from file metadata
 		fInfo, err := d.Info()
 		if err != nil {
-			f.Close()
 			return err
 		}
+		if cfg.MaxFileSize > 0 && fInfo.Size() > cfg.MaxFileSize {
+			logger.Warn(
+				"leaving out save file larger than the max file size",
+				zap.String("game", cfg.Name),
+				zap.String("file", filePath),
+				zap.Int64("size", fInfo.Size()),
+				zap.Int64("max", cfg.MaxFileSize),
+			)
+			return nil
+		}
 		if lastModified.Before(fInfo.ModTime()) {
 			lastModified = fInfo.ModTime()
 		}
 
 		saveFilePath := strings.ReplaceAll(path.Clean(filePath), "\\", "/")
 		saveFilePath = strings.TrimPrefix(saveFilePath, path.Clean(cfg.WorkingDir))
-		saveFiles[saveFilePath] = f
+		filePaths[saveFilePath] = filePath
 		return nil
 	}
 
-	// Call handler for each save file
+	// Call handler for each save file, within each directory matching a pattern
+	fsys := os.DirFS(workingDir)
 	for _, saveFile := range cfg.SaveFiles {
-		filePath := path.Join(cfg.WorkingDir, saveFile)
-		if err = filepath.WalkDir(filePath, handler); err != nil {
-			break
+		pattern := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(saveFile)), "/")
+		matches, err := doublestar.Glob(fsys, pattern, append(opts, doublestar.WithFailOnIOErrors())...)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("could not match [%s]: %w", saveFile, err)
+		}
+
+		for _, match := range matches {
+			if err = filepath.WalkDir(filepath.Join(workingDir, filepath.FromSlash(match)), handler); err != nil {
+				return nil, time.Time{}, err
+			}
 		}
 	}
 
-	return saveFiles, lastModified, err
+	return filePaths, lastModified, nil
 }
 
-func closeSaveFiles(saveFiles map[string]io.ReadSeekCloser) {
-	for _, f := range saveFiles {
-		f.Close()
+// isExcluded reports whether a path relative to the working directory matches any of the exclude patterns
+func isExcluded(exclude []string, relPath string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := doublestar.Match(pattern, relPath); ok { // Validated by the config
+			return true
+		}
 	}
+	return false
 }
@@ -1,22 +1,26 @@
 package backup
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"game-server/internal/config"
 	"game-server/internal/testing/mockserver"
-	"game-server/pkg/aws/s3"
 )
 
 func Test_Client_DoBackup(t *testing.T) {
-	bucketName := "save-bucket"
-	t.Setenv(EnvGameSaveBucket, bucketName)
-
 	// Override save frequency to ensure backup occurs
 	defer func(origFreq time.Duration) {
 		Frequency = origFreq
@@ -24,29 +28,313 @@ func Test_Client_DoBackup(t *testing.T) {
 	Frequency = time.Duration(0)
 
 	mockCfg := mockserver.GetConfig(t)
+	c := newTestClient(t, mockCfg)
+
+	err := c.DoBackup()
+
+	require.NoError(t, err)
 
-	// Setup mock S3 client
-	expFolderName := time.Now().Format(dateFolderFormat)
-	mockS3Client := new(s3.MockClient)
-	mockS3Client.On(s3.ConnectMethod).Return(nil)
-	mockS3Client.On(s3.GetFoldersMethod, bucketName, 2).Return(nil, nil)
+	// Ensure every save file was uploaded, along with the manifest
+	folder, keys, _, err := c.getBackup(mockserver.GameName, time.Now().Format(DateFormat))
+	require.NoError(t, err)
+	expKeys := []string{path.Join(folder, manifestName)}
 	for _, saveFile := range mockserver.SaveFilePaths {
-		mockS3Client.On(s3.PutMethod, mock.Anything, bucketName, path.Join(mockserver.GameName, expFolderName, saveFile)).Return(nil).Once()
+		expKeys = append(expKeys, path.Join(folder, saveFile))
 	}
+	assert.ElementsMatch(t, expKeys, keys)
+}
+
+func Test_Client_DoBackup_AfterSessionBackup(t *testing.T) {
+	workingDir := t.TempDir()
+	levelPath := filepath.Join(workingDir, "world", "level.dat")
+	require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+	require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
 
-	c := Client{
-		cfg:      mockCfg,
-		logger:   config.NewTestLogger(),
-		s3Bucket: bucketName,
-		s3Client: mockS3Client,
+	cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+		`[{"name": "Game", "working_directory": %q, "save_files": ["world"]}]`, workingDir,
+	)))
+	c := newTestClient(t, cfg)
+	date := time.Now().Format(DateFormat)
+	runs := func() int {
+		keys, err := c.storage.List(c.bucket, "Game/"+date+"/")
+		require.NoError(t, err)
+		return len(groupRuns("Game/"+date, keys).runs)
 	}
 
-	err := c.DoBackup()
+	// Unchanged save is not backed up again
+	time.Sleep(2 * time.Millisecond) // Saves modified as a run starts are backed up again
+	require.NoError(t, c.BackupGame(context.Background(), "Game"))
+	require.NoError(t, c.DoBackup())
+	assert.Equal(t, 1, runs())
+
+	// Save changed since is backed up, even on the same day
+	time.Sleep(2 * time.Millisecond) // Runs are told apart by the millisecond
+	require.NoError(t, os.WriteFile(levelPath, []byte("changed"), 0o644))
+	require.NoError(t, c.DoBackup())
+	assert.Equal(t, 2, runs())
 
+	// Run that failed after the save changed is not taken as a backup of it
+	require.NoError(t, os.WriteFile(levelPath, []byte("changed again"), 0o644))
+	time.Sleep(2 * time.Millisecond)
+	incomplete := runFolder("Game", time.Now())
+	require.NoError(t, c.storage.Put(strings.NewReader("partial"), c.bucket, path.Join(incomplete, "world/level.dat")))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, c.DoBackup())
+	folder, _, _, err := c.getBackup("Game", date)
 	require.NoError(t, err)
+	assert.Greater(t, folder, incomplete)
+}
+
+func Test_Client_ForceBackup(t *testing.T) {
+	mockCfg := mockserver.GetConfig(t)
+	c := newTestClient(t, mockCfg)
+
+	// Previous backup from today, which would otherwise skip the backup
+	expFolderName := time.Now().Format(DateFormat)
+	staleKey := path.Join(mockserver.GameName, expFolderName, mockserver.SaveFilePaths[0])
+	require.NoError(t, c.storage.Put(strings.NewReader("stale"), c.bucket, staleKey))
+
+	err := c.ForceBackup(mockserver.GameName)
 
-	// Ensure every save file was uploaded
+	require.NoError(t, err)
+	folder, _, _, err := c.getBackup(mockserver.GameName, expFolderName)
+	require.NoError(t, err)
 	for _, saveFile := range mockserver.SaveFilePaths {
-		mockS3Client.AssertCalled(t, s3.PutMethod, mock.Anything, bucketName, path.Join(mockserver.GameName, expFolderName, saveFile))
+		key := path.Join(folder, saveFile)
+		body, err := c.storage.Get(c.bucket, key)
+		require.NoError(t, err, key)
+		data, err := io.ReadAll(body)
+		body.Close()
+		require.NoError(t, err)
+
+		gameCfg, _ := mockCfg.GetGameConfig(mockserver.GameName)
+		expData, err := os.ReadFile(filepath.Join(gameCfg.WorkingDir, filepath.FromSlash(saveFile)))
+		require.NoError(t, err)
+		assert.Equal(t, string(expData), string(data), key)
 	}
 }
+
+func Test_Client_BackupGame(t *testing.T) {
+	mockCfg := mockserver.GetConfig(t)
+	c := newTestClient(t, mockCfg)
+
+	// Only the game is backed up, regardless of any earlier backup today
+	date := time.Now().Format(DateFormat)
+	require.NoError(t, c.BackupGame(context.Background(), mockserver.GameName))
+	first, _, _, err := c.getBackup(mockserver.GameName, date)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond) // Runs are told apart by the millisecond
+	require.NoError(t, c.BackupGame(context.Background(), mockserver.GameName))
+
+	// Later runs never write over an earlier backup
+	second, keys, _, err := c.getBackup(mockserver.GameName, date)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.Len(t, keys, len(mockserver.SaveFilePaths)+1)
+
+	assert.Error(t, c.BackupGame(context.Background(), "NotAGame"))
+}
+
+func Test_Client_getBackup(t *testing.T) {
+	date := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)
+	complete := runFolder("Game", date.Add(time.Hour))
+	incomplete := runFolder("Game", date.Add(2*time.Hour))
+
+	tests := []struct {
+		name      string
+		keys      []string
+		expFolder string
+		expErr    bool
+	}{
+		{
+			name: "Happy path - Latest complete run",
+			keys: []string{
+				"Game/2023-04-05/" + manifestName,
+				"Game/2023-04-05/world/level.dat",
+				complete + "/" + manifestName,
+				complete + "/world/level.dat",
+				incomplete + "/world/level.dat",
+			},
+			expFolder: complete,
+		},
+		{
+			name:      "Happy path - Backed up before runs had their own folder",
+			keys:      []string{"Game/2023-04-05/world/level.dat", incomplete + "/world/level.dat"},
+			expFolder: "Game/2023-04-05",
+		},
+		{
+			name:   "Sad path - No complete backup",
+			keys:   []string{incomplete + "/world/level.dat"},
+			expErr: true,
+		},
+		{
+			name:   "Sad path - No backup",
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, config.NewTestConfig(t, []byte(`[{"name": "Game"}]`)))
+			for _, key := range tt.keys {
+				require.NoError(t, c.storage.Put(strings.NewReader("{}"), c.bucket, key))
+			}
+
+			folder, keys, _, err := c.getBackup("Game", date.Format(DateFormat))
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expFolder, folder)
+			for _, key := range keys {
+				assert.True(t, strings.HasPrefix(key, folder+"/"), key)
+			}
+		})
+	}
+}
+
+// newTestClient gets a client storing backups in a local directory of its own
+func newTestClient(t *testing.T, cfg *config.Config) *Client {
+	storageURL := url.URL{Scheme: config.StorageFile, Path: filepath.ToSlash(t.TempDir())}
+	cfg.Settings.BackupStorage = storageURL.String()
+
+	c := New(cfg)
+	require.NoError(t, c.start())
+	return c
+}
+
+func Test_findSaveFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		saveFiles   []string
+		exclude     []string
+		maxFileSize int64
+		expFiles    []string
+		expErr      bool
+	}{
+		{
+			name:      "Happy path - Paths",
+			saveFiles: []string{"savefile1.txt", "savedir"},
+			expFiles: []string{
+				"/savefile1.txt",
+				"/savedir/savefile2.txt",
+				"/savedir/savefile3",
+				"/savedir/cache/chunk.tmp",
+				"/savedir/crash.dmp",
+			},
+		},
+		{
+			name:      "Happy path - Glob",
+			saveFiles: []string{"*.txt"},
+			expFiles:  []string{"/savefile1.txt", "/nonsavefile.txt"},
+		},
+		{
+			name:      "Happy path - Doublestar glob",
+			saveFiles: []string{"**/savefile*"},
+			expFiles:  []string{"/savefile1.txt", "/savedir/savefile2.txt", "/savedir/savefile3"},
+		},
+		{
+			name:      "Happy path - Exclude",
+			saveFiles: []string{"savefile1.txt", "savedir"},
+			exclude:   []string{"**/cache", "**/*.dmp"},
+			expFiles:  []string{"/savefile1.txt", "/savedir/savefile2.txt", "/savedir/savefile3"},
+		},
+		{
+			name:        "Happy path - Max file size",
+			saveFiles:   []string{"savedir"},
+			exclude:     []string{"savedir/cache/**"},
+			maxFileSize: 64,
+			expFiles:    []string{"/savedir/savefile2.txt", "/savedir/savefile3"},
+		},
+		{
+			name:      "Sad path - Missing path",
+			saveFiles: []string{"savefile1.txt", "missing.txt"},
+			expErr:    true,
+		},
+		{
+			name:      "Sad path - Glob in missing directory",
+			saveFiles: []string{"missing/**/*.dat"},
+			expErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameCfg := &config.GameConfig{
+				Name:        "Game",
+				WorkingDir:  "testdata/savedata",
+				SaveFiles:   tt.saveFiles,
+				Exclude:     tt.exclude,
+				MaxFileSize: tt.maxFileSize,
+			}
+
+			filePaths, _, err := findSaveFiles(config.NewTestLogger(), gameCfg)
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var files []string
+			for saveFilePath, filePath := range filePaths {
+				files = append(files, saveFilePath)
+				assert.FileExists(t, filePath)
+			}
+			assert.ElementsMatch(t, tt.expFiles, files)
+		})
+	}
+}
+
+func Test_Client_Concurrent(t *testing.T) {
+	workingDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workingDir, "world"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "world", "level.dat"), []byte("level data"), 0o644))
+	cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+		`[{"name": "Game", "working_directory": %q, "save_files": ["world"]}]`, workingDir,
+	)))
+	require.NoError(t, newTestClient(t, cfg).BackupGame(context.Background(), "Game"))
+
+	// Backups and verification share a client that is yet to be started, as the service and scheduler do
+	c := New(cfg)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.BackupGame(context.Background(), "Game"))
+		}()
+		go func() {
+			defer wg.Done()
+			c.Verify(context.Background(), "Game", time.Time{}) // May see a backup being written
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Client_BackupGame_Cancelled(t *testing.T) {
+	mockCfg := mockserver.GetConfig(t)
+	c := newTestClient(t, mockCfg)
+
+	// Cancelled once the upload has begun
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.storage = &cancelingStorage{Storage: c.storage, cancel: cancel}
+
+	err := c.BackupGame(ctx, mockserver.GameName)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, _, err = c.getBackup(mockserver.GameName, time.Now().Format(DateFormat))
+	assert.Error(t, err, "Cancelled backup is incomplete")
+}
+
+// cancelingStorage cancels a context as soon as a file is written
+type cancelingStorage struct {
+	Storage
+
+	cancel context.CancelFunc
+}
+
+func (s *cancelingStorage) Put(file io.ReadSeeker, bucket string, key string) error {
+	s.cancel()
+	return s.Storage.Put(file, bucket, key)
+}
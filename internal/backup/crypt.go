@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// Master key used to wrap the data key of each backup, base64 encoded, either directly or from a file
+	EnvBackupKey     = "BACKUP_KEY"
+	EnvBackupKeyFile = "BACKUP_KEY_FILE"
+
+	keySize = 32 // AES-256
+
+	// Content is sealed in chunks so large files can be streamed
+	chunkSize = 64 * 1024
+)
+
+// Encrypted objects start with this, followed by their base nonce. The data key they are sealed with
+// is kept wrapped by the master key in the manifest of their backup.
+var encryptedMagic = []byte("GSENC\x00\x02\x00")
+
+var (
+	ErrNoKey    = errors.New("backup is encrypted but no backup key is configured")
+	ErrWrongKey = errors.New("backup was encrypted with a different backup key")
+	ErrCorrupt  = errors.New("encrypted backup is corrupt")
+
+	ErrNotEncrypted = errors.New("backup should be encrypted but is not")
+)
+
+// loadKey gets the master key from the env, which is nil if backups are not encrypted
+func loadKey() ([]byte, error) {
+	encoded := os.Getenv(EnvBackupKey)
+	if keyFile := os.Getenv(EnvBackupKeyFile); encoded == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read backup key file: %w", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup key: %w", err)
+	} else if len(key) != keySize {
+		return nil, fmt.Errorf("backup key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// sealer encrypts the objects of a single backup with its own data key
+type sealer struct {
+	aead    cipher.AEAD
+	wrapped string // Data key encrypted by the master key, base64 encoded
+	keyID   string // Identifies the master key
+}
+
+// newSealer generates a data key for a backup, nil if there is no master key to wrap it with
+func newSealer(masterKey []byte) (*sealer, error) {
+	if masterKey == nil {
+		return nil, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrapped key is its nonce followed by the sealed data key
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &sealer{
+		aead:    aead,
+		wrapped: base64.StdEncoding.EncodeToString(master.Seal(nonce, nonce, dataKey, nil)),
+		keyID:   keyID(masterKey),
+	}, nil
+}
+
+// unwrapKey gets the data key of a backup from its wrapped form in the manifest
+func unwrapKey(masterKey []byte, id string, wrappedKey string) (cipher.AEAD, error) {
+	if masterKey == nil {
+		return nil, ErrNoKey
+	} else if id != keyID(masterKey) {
+		return nil, ErrWrongKey
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	} else if len(wrapped) < master.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	dataKey, err := master.Open(nil, wrapped[:master.NonceSize()], wrapped[master.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return newAEAD(dataKey)
+}
+
+// keyID identifies the master key without revealing it, so objects sealed with a different key can be told apart
+func keyID(masterKey []byte) string {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte("backup key id"))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// dataKey gets the data key a file of the backup is sealed with, nil if the backup is not encrypted
+func (c *Client) dataKey(manifest *Manifest, file ManifestFile) (cipher.AEAD, error) {
+	if !manifest.Encrypted {
+		return nil, nil
+	}
+	return unwrapKey(c.key, manifest.KeyID, manifest.fileKey(file))
+}
+
+// seal returns the encrypted contents of the reader, which must be closed to stop encrypting
+func (s *sealer) seal(src io.Reader) *io.PipeReader {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(s.write(w, src))
+	}()
+	return r
+}
+
+func (s *sealer) write(w io.Writer, src io.Reader) error {
+	baseNonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(baseNonce); err != nil {
+		return err
+	}
+
+	header := append([]byte{}, encryptedMagic...)
+	header = append(header, baseNonce...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// Read a chunk ahead, so the last chunk can be marked as such
+	chunk, next := make([]byte, chunkSize), make([]byte, chunkSize)
+	n, err := io.ReadFull(src, chunk)
+	for counter := uint64(0); ; counter++ {
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+
+		last := err != nil
+		var nextN int
+		var nextErr error
+		if !last {
+			nextN, nextErr = io.ReadFull(src, next)
+			last = nextN == 0 && errors.Is(nextErr, io.EOF)
+		}
+
+		sealed := s.aead.Seal(nil, chunkNonce(baseNonce, counter), chunk[:n], chunkAD(last))
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		} else if last {
+			return nil
+		}
+
+		chunk, next = next, chunk
+		n, err = nextN, nextErr
+	}
+}
+
+// open returns the decrypted contents of the reader with the data key of its backup, or the contents as is
+// if not encrypted, which must be closed to stop decrypting. Contents that are not encrypted are refused if they should be.
+func open(aead cipher.AEAD, src io.Reader, encrypted bool) (io.ReadCloser, error) {
+	br := bufio.NewReader(src)
+	magic, err := br.Peek(len(encryptedMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	} else if !bytes.Equal(magic, encryptedMagic) {
+		if encrypted {
+			return nil, ErrNotEncrypted
+		}
+		return io.NopCloser(br), nil
+	} else if aead == nil {
+		return nil, ErrNoKey
+	}
+	br.Discard(len(encryptedMagic))
+
+	baseNonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(br, baseNonce); err != nil {
+		return nil, ErrCorrupt
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(openChunks(w, br, aead, baseNonce))
+	}()
+	return r, nil
+}
+
+func openChunks(w io.Writer, br *bufio.Reader, aead cipher.AEAD, baseNonce []byte) error {
+	sealed := make([]byte, chunkSize+aead.Overhead())
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, sealed)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrCorrupt
+		}
+
+		// Last chunk is either short or followed by nothing
+		last := err != nil
+		if !last {
+			if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+
+		chunk, err := aead.Open(nil, chunkNonce(baseNonce, counter), sealed[:n], chunkAD(last))
+		if err != nil {
+			return ErrCorrupt
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		} else if last {
+			return nil
+		}
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce gets a unique nonce for each chunk of an object from its base nonce
+func chunkNonce(baseNonce []byte, counter uint64) []byte {
+	nonce := append([]byte{}, baseNonce...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^counter)
+	return nonce
+}
+
+// chunkAD marks the last chunk, so a truncated object cannot be mistaken for a whole one
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
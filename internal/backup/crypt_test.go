@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+)
+
+func Test_sealer(t *testing.T) {
+	key := newTestKey(t)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "Happy path - Empty", size: 0},
+		{name: "Happy path - Single chunk", size: 100},
+		{name: "Happy path - Exact chunk", size: chunkSize},
+		{name: "Happy path - Multiple chunks", size: 3*chunkSize + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			_, err := rand.Read(data)
+			require.NoError(t, err)
+
+			s, err := newSealer(key)
+			require.NoError(t, err)
+			sealed, err := io.ReadAll(s.seal(bytes.NewReader(data)))
+			require.NoError(t, err)
+			assert.False(t, tt.size > 0 && bytes.Contains(sealed, data))
+
+			// Data key is unwrapped from its manifest form
+			aead, err := unwrapKey(key, s.keyID, s.wrapped)
+			require.NoError(t, err)
+			r, err := open(aead, bytes.NewReader(sealed), true)
+			require.NoError(t, err)
+			opened, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, data, opened)
+
+			// Dropping the last chunk is detected
+			if tt.size > chunkSize {
+				r, err := open(aead, bytes.NewReader(sealed[:len(sealed)-(tt.size%chunkSize)-s.aead.Overhead()]), true)
+				require.NoError(t, err)
+				_, err = io.ReadAll(r)
+				assert.ErrorIs(t, err, ErrCorrupt)
+			}
+		})
+	}
+}
+
+func Test_unwrapKey(t *testing.T) {
+	key := newTestKey(t)
+	s, err := newSealer(key)
+	require.NoError(t, err)
+
+	_, err = unwrapKey(nil, s.keyID, s.wrapped)
+	assert.ErrorIs(t, err, ErrNoKey)
+	_, err = unwrapKey(newTestKey(t), s.keyID, s.wrapped)
+	assert.ErrorIs(t, err, ErrWrongKey)
+	_, err = unwrapKey(key, s.keyID, "not base64")
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func Test_Client_EncryptedBackup(t *testing.T) {
+	backupKey := newTestKey(t)
+	date := time.Now().Format(DateFormat)
+	parsedDate, _ := time.Parse(DateFormat, date)
+
+	tests := []struct {
+		name       string
+		format     string
+		restoreKey []byte
+		expErr     error
+	}{
+		{
+			name:       "Happy path - Files",
+			format:     config.BackupFiles,
+			restoreKey: backupKey,
+		},
+		{
+			name:       "Happy path - Archive",
+			format:     config.BackupTarGz,
+			restoreKey: backupKey,
+		},
+		{
+			name:       "Happy path - Incremental",
+			format:     config.BackupIncremental,
+			restoreKey: backupKey,
+		},
+		{
+			name:       "Sad path - Wrong key",
+			format:     config.BackupFiles,
+			restoreKey: newTestKey(t),
+			expErr:     ErrWrongKey,
+		},
+		{
+			name:       "Sad path - Wrong key for archive",
+			format:     config.BackupTarGz,
+			restoreKey: newTestKey(t),
+			expErr:     ErrWrongKey,
+		},
+		{
+			name:   "Sad path - No key",
+			format: config.BackupFiles,
+			expErr: ErrNoKey,
+		},
+		{
+			name:   "Sad path - No key for incremental",
+			format: config.BackupIncremental,
+			expErr: ErrNoKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			levelPath := filepath.Join(workingDir, "world", "level.dat")
+			require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+			require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
+
+			cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+				`[{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": %q}]`, workingDir, tt.format,
+			)))
+			gameCfg, _ := cfg.GetGameConfig("Game")
+
+			c := newTestClient(t, cfg)
+			c.key = backupKey
+			require.NoError(t, c.backupGame(context.Background(), gameCfg, time.Time{}))
+
+			// Nothing is stored in plaintext, besides any manifest, which holds the wrapped data key
+			keys, err := c.storage.List(c.bucket, "Game/")
+			require.NoError(t, err)
+			for _, key := range keys {
+				if filepath.Base(key) == manifestName {
+					manifest, err := c.getManifest(filepath.Dir(key))
+					require.NoError(t, err)
+					assert.True(t, manifest.Encrypted)
+					assert.Equal(t, keyID(backupKey), manifest.KeyID)
+					assert.NotEmpty(t, manifest.WrappedKey)
+					continue
+				}
+				body, err := c.storage.Get(c.bucket, key)
+				require.NoError(t, err)
+				data, err := io.ReadAll(body)
+				require.NoError(t, err)
+				assert.True(t, bytes.HasPrefix(data, encryptedMagic), key)
+			}
+
+			// Restore with the key from the env, which is loaded by a new client
+			require.NoError(t, os.WriteFile(levelPath, []byte("changed"), 0o644))
+			if tt.restoreKey != nil {
+				t.Setenv(EnvBackupKey, base64.StdEncoding.EncodeToString(tt.restoreKey))
+			} else {
+				t.Setenv(EnvBackupKey, "")
+			}
+			err = New(cfg).Restore("Game", parsedDate)
+
+			data, readErr := os.ReadFile(levelPath)
+			require.NoError(t, readErr)
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+
+				// Current save is left in place
+				assert.Equal(t, "changed", string(data))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "level data", string(data))
+		})
+	}
+}
+
+func Test_Client_EncryptedBackup_Plaintext(t *testing.T) {
+	backupKey := newTestKey(t)
+	date := time.Now().Format(DateFormat)
+	parsedDate, _ := time.Parse(DateFormat, date)
+
+	for _, format := range []string{config.BackupFiles, config.BackupTarGz, config.BackupIncremental} {
+		t.Run("Sad path - "+format, func(t *testing.T) {
+			workingDir := t.TempDir()
+			levelPath := filepath.Join(workingDir, "world", "level.dat")
+			require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+			require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
+
+			cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+				`[{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": %q}]`, workingDir, format,
+			)))
+			gameCfg, _ := cfg.GetGameConfig("Game")
+
+			c := newTestClient(t, cfg)
+			c.key = backupKey
+			require.NoError(t, c.backupGame(context.Background(), gameCfg, time.Time{}))
+
+			// Replace every encrypted object with plaintext, leaving the manifest saying it is encrypted
+			keys, err := c.storage.List(c.bucket, "Game/")
+			require.NoError(t, err)
+			for _, key := range keys {
+				if filepath.Base(key) != manifestName {
+					require.NoError(t, c.storage.Put(bytes.NewReader([]byte("level data")), c.bucket, key))
+				}
+			}
+
+			require.NoError(t, os.WriteFile(levelPath, []byte("changed"), 0o644))
+			assert.ErrorIs(t, c.Restore("Game", parsedDate), ErrNotEncrypted)
+			data, err := os.ReadFile(levelPath)
+			require.NoError(t, err)
+			assert.Equal(t, "changed", string(data))
+
+			var verifyErr VerifyError
+			assert.ErrorAs(t, c.Verify(context.Background(), "Game", parsedDate), &verifyErr)
+		})
+	}
+}
+
+func Test_loadKey(t *testing.T) {
+	key := newTestKey(t)
+	encoded := base64.StdEncoding.EncodeToString(key)
+	keyFile := filepath.Join(t.TempDir(), "backup.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(encoded+"\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		key     string
+		keyFile string
+		expKey  []byte
+		expErr  bool
+	}{
+		{
+			name:   "Happy path - Env",
+			key:    encoded,
+			expKey: key,
+		},
+		{
+			name:    "Happy path - File",
+			keyFile: keyFile,
+			expKey:  key,
+		},
+		{
+			name: "Happy path - Not encrypted",
+		},
+		{
+			name:   "Sad path - Wrong size",
+			key:    base64.StdEncoding.EncodeToString(key[:16]),
+			expErr: true,
+		},
+		{
+			name:    "Sad path - Missing file",
+			keyFile: filepath.Join(t.TempDir(), "missing.key"),
+			expErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvBackupKey, tt.key)
+			t.Setenv(EnvBackupKeyFile, tt.keyFile)
+
+			got, err := loadKey()
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expKey, got)
+		})
+	}
+}
+
+func newTestKey(t *testing.T) []byte {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
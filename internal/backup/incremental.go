@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/config"
+	"game-server/pkg/aws/s3"
+)
+
+// Files of incremental backups are shared between backups of a game, stored in this folder by their hash.
+// Encrypted files are kept in a folder of the backup key their data key is wrapped by, so they are only shared
+// between backups able to unwrap it.
+const blobFolder = "blobs"
+
+// putIncremental stores each save file by its hash, skipping any already stored, followed by a manifest of the backup
+func (c *Client) putIncremental(game string, folder string, saveFiles map[string]io.ReadSeekCloser, s *sealer) error {
+	var keyID string
+	if s != nil {
+		keyID = s.keyID
+	}
+
+	keys, err := c.storage.List(c.bucket, blobPrefix(game))
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		stored[key] = struct{}{}
+	}
+
+	// Encrypted blobs can only be shared with the data key they are sealed with
+	var wrappedKeys map[string]string
+	if s != nil {
+		if wrappedKeys, err = c.blobKeys(game, keyID); err != nil {
+			return err
+		}
+	}
+
+	// Sort for a consistent manifest
+	filePaths := sortedPaths(saveFiles)
+
+	uploaded := 0
+	manifest := &Manifest{
+		Format: config.BackupIncremental,
+		Files:  make([]ManifestFile, 0, len(filePaths)),
+	}
+	manifest.setKey(s)
+	for _, filePath := range filePaths {
+		f := saveFiles[filePath]
+		file, err := hashFile(filePath, f)
+		if err != nil {
+			return err
+		}
+
+		key := blobKey(game, file.SHA256, keyID)
+		if _, ok := stored[key]; ok && s == nil {
+			manifest.Files = append(manifest.Files, file)
+			continue
+		} else if wrapped, known := wrappedKeys[file.SHA256]; ok && known {
+			// Blobs stored by an earlier backup keep the data key they were sealed with
+			if wrapped != s.wrapped {
+				file.WrappedKey = wrapped
+			}
+			manifest.Files = append(manifest.Files, file)
+			continue
+		}
+
+		if err := c.put(f, key, s); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, file)
+		stored[key] = struct{}{}
+		if s != nil {
+			wrappedKeys[file.SHA256] = s.wrapped
+		}
+		uploaded++
+	}
+
+	c.logger.Info("uploaded changed save files", zap.String("game", game), zap.Int("uploaded", uploaded), zap.Int("files", len(filePaths)))
+	return c.putManifest(folder, manifest)
+}
+
+// blobKeys gets the wrapped data key of each stored blob by its hash, from the manifests of backups
+// encrypted with the backup key. Blobs in no such manifest are stored again, as their data key is unknown.
+func (c *Client) blobKeys(game string, keyID string) (map[string]string, error) {
+	keys, err := c.storage.List(c.bucket, game+s3.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKeys := make(map[string]string)
+	for _, key := range keys {
+		if path.Base(key) != manifestName {
+			continue
+		}
+		manifest, err := c.getManifest(path.Dir(key))
+		if err != nil {
+			return nil, err
+		} else if manifest.Format != config.BackupIncremental || !manifest.Encrypted || manifest.KeyID != keyID {
+			continue
+		}
+		for _, file := range manifest.Files {
+			wrappedKeys[file.SHA256] = manifest.fileKey(file)
+		}
+	}
+	return wrappedKeys, nil
+}
+
+// getBlobs writes each file of an incremental backup to the working directory, checking it against the manifest
+func (c *Client) getBlobs(game string, workingDir string, manifest *Manifest) error {
+	for _, file := range manifest.Files {
+		filePath, err := restorePath(workingDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := c.downloadChecked(blobKey(game, file.SHA256, manifest.KeyID), filePath, manifest, file); err != nil {
+			return fmt.Errorf("could not restore [%s]: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// unreferencedBlobs gets the blobs not referenced by any of the manifests
+func (c *Client) unreferencedBlobs(game string, blobKeys []string, manifestKeys []string) ([]string, error) {
+	referenced := make(map[string]struct{})
+	for _, manifestKey := range manifestKeys {
+		manifest, err := c.getManifest(path.Dir(manifestKey))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range manifest.Files {
+			referenced[blobKey(game, file.SHA256, manifest.KeyID)] = struct{}{}
+		}
+	}
+
+	var garbage []string
+	for _, key := range blobKeys {
+		if _, ok := referenced[key]; !ok {
+			garbage = append(garbage, key)
+		}
+	}
+	return garbage, nil
+}
+
+func blobPrefix(game string) string {
+	return path.Join(game, blobFolder) + s3.Delimiter
+}
+
+// blobKey gets where a file is stored by its hash, within the folder of the backup key it is encrypted with if any
+func blobKey(game string, sum string, keyID string) string {
+	return path.Join(game, blobFolder, keyID, sum)
+}
@@ -0,0 +1,171 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+)
+
+func Test_Client_IncrementalBackup(t *testing.T) {
+	workingDir := t.TempDir()
+	writeSave := func(filePath string, data string) {
+		filePath = filepath.Join(workingDir, filepath.FromSlash(filePath))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+		require.NoError(t, os.WriteFile(filePath, []byte(data), 0o644))
+	}
+	readSave := func(filePath string) string {
+		data, err := os.ReadFile(filepath.Join(workingDir, filepath.FromSlash(filePath)))
+		require.NoError(t, err)
+		return string(data)
+	}
+	writeSave("world/level.dat", "level day one")
+	writeSave("world/region/r.0.0", "region")
+
+	cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+		`{"retention": {"daily": 1}, "games": [{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": "incremental"}]}`,
+		workingDir,
+	)))
+	gameCfg, _ := cfg.GetGameConfig("Game")
+
+	c := newTestClient(t, cfg)
+	storage := &recordingStorage{Storage: c.storage}
+	c.storage = storage
+	backup := func(date string) {
+		filePaths, _, err := findSaveFiles(c.logger, gameCfg)
+		require.NoError(t, err)
+		snap, err := takeSnapshot(filePaths)
+		require.NoError(t, err)
+		defer snap.Close()
+		require.NoError(t, c.putIncremental(gameCfg.Name, "Game/"+date, snap.files, nil))
+	}
+	blobPuts := func() int {
+		count := 0
+		for _, key := range storage.puts {
+			if strings.HasPrefix(key, "Game/blobs/") {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Every file is uploaded on the first backup
+	backup("2023-03-01")
+	assert.Equal(t, 2, blobPuts())
+
+	// Only the changed file is uploaded after
+	writeSave("world/level.dat", "level day two")
+	backup("2023-03-02")
+	assert.Equal(t, 3, blobPuts())
+
+	// Either backup can be restored
+	restore := func(date string) {
+		parsedDate, err := time.Parse(DateFormat, date)
+		require.NoError(t, err)
+		require.NoError(t, c.Restore("Game", parsedDate))
+	}
+	restore("2023-03-01")
+	assert.Equal(t, "level day one", readSave("world/level.dat"))
+	assert.Equal(t, "region", readSave("world/region/r.0.0"))
+	restore("2023-03-02")
+	assert.Equal(t, "level day two", readSave("world/level.dat"))
+
+	// Pruning the first backup collects the blob only it referenced
+	require.NoError(t, c.prune(gameCfg))
+	blobs, err := c.storage.List(c.bucket, "Game/blobs/")
+	require.NoError(t, err)
+	assert.Len(t, blobs, 2)
+	manifests, err := c.storage.List(c.bucket, "Game/2023-03-01/")
+	require.NoError(t, err)
+	assert.Empty(t, manifests)
+
+	// Remaining backup is still whole
+	writeSave("world/region/r.0.0", "corrupted")
+	restore("2023-03-02")
+	assert.Equal(t, "level day two", readSave("world/level.dat"))
+	assert.Equal(t, "region", readSave("world/region/r.0.0"))
+}
+
+func Test_Client_IncrementalBackup_KeyChange(t *testing.T) {
+	workingDir := t.TempDir()
+	levelPath := filepath.Join(workingDir, "world", "level.dat")
+	require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+	require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
+
+	cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+		`[{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": "incremental"}]`, workingDir,
+	)))
+	gameCfg, _ := cfg.GetGameConfig("Game")
+
+	c := newTestClient(t, cfg)
+	storage := &recordingStorage{Storage: c.storage}
+	c.storage = storage
+	backup := func(key []byte, date string) {
+		c.key = key
+		filePaths, _, err := findSaveFiles(c.logger, gameCfg)
+		require.NoError(t, err)
+		snap, err := takeSnapshot(filePaths)
+		require.NoError(t, err)
+		defer snap.Close()
+		s, err := newSealer(key)
+		require.NoError(t, err)
+		require.NoError(t, c.putIncremental(gameCfg.Name, "Game/"+date, snap.files, s))
+	}
+
+	// Unchanged file is uploaded again once encrypted, and again with each new key
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	backup(nil, "2023-03-01")
+	backup(oldKey, "2023-03-02")
+	backup(oldKey, "2023-03-03")
+	backup(newKey, "2023-03-04")
+	blobs, err := c.storage.List(c.bucket, "Game/blobs/")
+	require.NoError(t, err)
+	assert.Len(t, blobs, 3)
+
+	// Latest backup only needs the new key
+	restore := func(key []byte, date string) {
+		c.key = key
+		require.NoError(t, os.WriteFile(levelPath, []byte("changed"), 0o644))
+		parsedDate, _ := time.Parse(DateFormat, date)
+		require.NoError(t, c.Restore("Game", parsedDate))
+		data, err := os.ReadFile(levelPath)
+		require.NoError(t, err)
+		assert.Equal(t, "level data", string(data))
+	}
+	restore(newKey, "2023-03-04")
+
+	// Blob shared with an earlier backup is opened with the data key it was sealed with
+	earlier, err := c.getManifest("Game/2023-03-02")
+	require.NoError(t, err)
+	shared, err := c.getManifest("Game/2023-03-03")
+	require.NoError(t, err)
+	require.Len(t, shared.Files, 1)
+	assert.Equal(t, earlier.WrappedKey, shared.Files[0].WrappedKey)
+	assert.NotEqual(t, earlier.WrappedKey, shared.WrappedKey)
+	restore(oldKey, "2023-03-03")
+}
+
+// recordingStorage records the key of every file stored
+type recordingStorage struct {
+	Storage
+
+	puts []string
+}
+
+func (r *recordingStorage) Put(file io.ReadSeeker, bucket string, key string) error {
+	r.puts = append(r.puts, key)
+	return r.Storage.Put(file, bucket, key)
+}
+
+func (r *recordingStorage) Upload(body io.Reader, bucket string, key string) error {
+	r.puts = append(r.puts, key)
+	return r.Storage.Upload(body, bucket, key)
+}
@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE ioctl, from linux/fs.h
+const ficlone = 0x40049409
+
+// reflink makes dst share the data of src, failing if the filesystem does not support it
+func reflink(dst *os.File, src *os.File) error {
+	rawDst, err := dst.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rawSrc, err := src.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = rawDst.Control(func(dstFd uintptr) {
+		err := rawSrc.Control(func(srcFd uintptr) {
+			_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, dstFd, ficlone, srcFd)
+		})
+		if err != nil {
+			errno = syscall.EBADF
+		}
+	})
+	if err != nil {
+		return err
+	} else if errno != 0 {
+		return errno
+	}
+	return nil
+}
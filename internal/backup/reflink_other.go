@@ -0,0 +1,13 @@
+//go:build !linux
+
+package backup
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is only supported on Linux, files are copied elsewhere
+func reflink(dst *os.File, src *os.File) error {
+	return errors.New("reflink not supported")
+}
@@ -0,0 +1,399 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/config"
+	"game-server/internal/gamelock"
+	"game-server/pkg/aws/s3"
+)
+
+// Current save files are moved into a folder of this format in the working directory before a restore
+const asideFolderFormat = "pre-restore-20060102-150405"
+
+// Files of a backup are downloaded into a folder with this prefix in the working directory
+// before any are put in place
+const stagingFolderPrefix = ".restore-staging-"
+
+// GameRunningError is returned when restoring a game that is still running
+type GameRunningError struct {
+	Game string
+}
+
+func (e GameRunningError) Error() string {
+	return fmt.Sprintf("cannot restore %s while it is running", e.Game)
+}
+
+// Restore replaces the save files of a game with those backed up on the given date,
+// moving the current save files aside first
+func (c *Client) Restore(game string, date time.Time) error {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
+	if !ok {
+		return fmt.Errorf("no configuration for game: [%s]", game)
+	}
+
+	// Lock the game against being run by the service, ports are checked as well
+	// for a game started some other way
+	lock, err := gamelock.Acquire(gameCfg.WorkingDir, gameCfg.Name)
+	if errors.Is(err, gamelock.ErrLocked) {
+		return GameRunningError{Game: gameCfg.Name}
+	} else if err != nil {
+		return fmt.Errorf("could not lock %s: %w", gameCfg.Name, err)
+	}
+	defer lock.Release()
+	if portsInUse(gameCfg.Ports) {
+		return GameRunningError{Game: gameCfg.Name}
+	}
+
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	// Get every file in the latest backup from the date
+	dateFolder := date.Format(DateFormat)
+	folder, keys, manifest, err := c.getBackup(gameCfg.Name, dateFolder)
+	if err != nil {
+		return err
+	}
+	prefix := folder + s3.Delimiter
+
+	// Check where each file is restored to before changing anything
+	var stage func(stagingDir string) error
+	switch {
+	case manifest != nil:
+		for _, file := range manifest.Files {
+			if _, err := restorePath(gameCfg.WorkingDir, file.Path); err != nil {
+				return err
+			}
+		}
+		if _, err := c.dataKey(manifest, ManifestFile{}); err != nil {
+			return fmt.Errorf("could not decrypt %s backup: %w", gameCfg.Name, err)
+		}
+
+		switch manifest.Format {
+		case config.BackupTarGz:
+			stage = func(stagingDir string) error {
+				return c.extractArchive(folder, stagingDir, manifest)
+			}
+
+		// An incremental backup is only its manifest, with the files stored as shared blobs
+		case config.BackupIncremental:
+			stage = func(stagingDir string) error {
+				return c.getBlobs(gameCfg.Name, stagingDir, manifest)
+			}
+
+		default:
+			stage = func(stagingDir string) error {
+				for _, file := range manifest.Files {
+					filePath, _ := restorePath(stagingDir, file.Path)
+					if err := c.downloadChecked(path.Join(folder, file.Path), filePath, manifest, file); err != nil {
+						return fmt.Errorf("could not restore [%s]: %w", file.Path, err)
+					}
+				}
+				return nil
+			}
+		}
+
+	// Files backed up before they had a manifest
+	default:
+		keys = append([]string{}, keys...)
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, err := restorePath(gameCfg.WorkingDir, strings.TrimPrefix(key, prefix)); err != nil {
+				return err
+			}
+		}
+		stage = func(stagingDir string) error {
+			for _, key := range keys {
+				filePath, _ := restorePath(stagingDir, strings.TrimPrefix(key, prefix))
+				if err := c.download(key, filePath); err != nil {
+					return fmt.Errorf("could not restore [%s]: %w", key, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	// Every file is downloaded and checked before the current save is touched,
+	// so a backup that cannot be restored in full leaves it as it was
+	workingDir := gameCfg.WorkingDir
+	if workingDir == "" {
+		workingDir = "."
+	}
+	stagingDir, err := os.MkdirTemp(workingDir, stagingFolderPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("could not stage %s backup: %w", gameCfg.Name, err)
+	}
+	defer os.RemoveAll(stagingDir)
+	if err := stage(stagingDir); err != nil {
+		if errors.Is(err, ErrNoKey) || errors.Is(err, ErrWrongKey) {
+			return fmt.Errorf("could not decrypt %s backup: %w", gameCfg.Name, err)
+		}
+		return err
+	}
+
+	asideDir, err := moveAside(c.logger, gameCfg, time.Now())
+	if err != nil {
+		c.moveBack(gameCfg, asideDir, nil)
+		return fmt.Errorf("could not move %s save files aside: %w", gameCfg.Name, err)
+	}
+	c.logger.Info(
+		"restoring save",
+		zap.String("game", gameCfg.Name),
+		zap.String("date", dateFolder),
+		zap.String("previous", asideDir),
+	)
+	if placed, err := moveInto(stagingDir, gameCfg.WorkingDir); err != nil {
+		c.moveBack(gameCfg, asideDir, placed)
+		return fmt.Errorf("could not put %s backup in place: %w", gameCfg.Name, err)
+	}
+	return nil
+}
+
+// moveBack undoes a restore that failed partway, removing the restored files already in place
+// and moving the save files back from the folder they were moved aside into
+func (c *Client) moveBack(cfg *config.GameConfig, asideDir string, placed []string) {
+	for _, filePath := range placed {
+		os.Remove(filePath)
+	}
+	if _, err := moveInto(asideDir, cfg.WorkingDir); err != nil {
+		c.logger.Error("could not move save files back", zap.String("game", cfg.Name), zap.String("previous", asideDir), zap.Error(err))
+		return
+	}
+	os.RemoveAll(asideDir)
+}
+
+func (c *Client) download(key string, filePath string) error {
+	body, err := c.storage.Get(c.bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Files backed up before they had a manifest were never encrypted
+	file, err := open(nil, body, false)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeFile(filePath, file)
+}
+
+// downloadChecked downloads the file, only putting it in place once checked against its hash in the manifest
+func (c *Client) downloadChecked(key string, filePath string, manifest *Manifest, file ManifestFile) error {
+	aead, err := c.dataKey(manifest, file)
+	if err != nil {
+		return err
+	}
+	body, err := c.storage.Get(c.bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	r, err := open(aead, body, manifest.Encrypted)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return writeFileChecked(filePath, r, file.SHA256)
+}
+
+// objectKeys gets the keys of the objects holding the files of a backup
+func (c *Client) objectKeys(game string, folder string, manifest *Manifest) []string {
+	switch manifest.Format {
+	case config.BackupTarGz:
+		return []string{path.Join(folder, archiveName)}
+	case config.BackupIncremental:
+		keys := make([]string, len(manifest.Files))
+		for i, file := range manifest.Files {
+			keys[i] = blobKey(game, file.SHA256, manifest.KeyID)
+		}
+		return keys
+	}
+
+	keys := make([]string, len(manifest.Files))
+	for i, file := range manifest.Files {
+		keys[i] = path.Join(folder, file.Path)
+	}
+	return keys
+}
+
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// restorePath gets where a backed up file is restored to, which must be within the working directory
+func restorePath(workingDir string, filePath string) (string, error) {
+	filePath = path.Clean(strings.TrimPrefix(filePath, "/"))
+	if filePath == "." || filePath == ".." || strings.HasPrefix(filePath, "../") {
+		return "", fmt.Errorf("invalid file in backup: [%s]", filePath)
+	}
+	return filepath.Join(workingDir, filepath.FromSlash(filePath)), nil
+}
+
+// writeFile writes the file alongside its path, only moving it into place once written in full
+func writeFile(filePath string, r io.Reader) error {
+	return placeFile(filePath, r, nil)
+}
+
+// writeFileChecked writes the file alongside its path, only moving it into place once its contents match the hash,
+// so a file that does not match is never left to be loaded by the game
+func writeFileChecked(filePath string, r io.Reader, sum string) error {
+	hash := sha256.New()
+	return placeFile(filePath, io.TeeReader(r, hash), func() error {
+		if hex.EncodeToString(hash.Sum(nil)) != sum {
+			return fmt.Errorf("checksum mismatch")
+		}
+		return nil
+	})
+}
+
+// placeFile writes the file alongside its path, moving it into place once written and passing any check
+func placeFile(filePath string, r io.Reader, check func() error) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // Nothing left to remove once moved into place
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), filePath)
+}
+
+// moveInto moves every file in the folder to the same path within the working directory,
+// getting the paths of the files moved so far
+func moveInto(dir string, workingDir string) (placed []string, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	err = filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		placedPath := filepath.Join(workingDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(placedPath), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filePath, placedPath); err != nil {
+			return err
+		}
+		placed = append(placed, placedPath)
+		return nil
+	})
+	return placed, err
+}
+
+// moveAside moves the current save files into a timestamped folder in the working directory,
+// getting the folder even if not every file could be moved
+func moveAside(logger *zap.Logger, cfg *config.GameConfig, now time.Time) (string, error) {
+	asideDir := filepath.Join(cfg.WorkingDir, now.Format(asideFolderFormat))
+
+	// Never mix files from different restores
+	for i := 1; ; i++ {
+		if _, err := os.Stat(asideDir); os.IsNotExist(err) {
+			break
+		}
+		asideDir = filepath.Join(cfg.WorkingDir, fmt.Sprintf("%s-%d", now.Format(asideFolderFormat), i))
+	}
+
+	// Only the files that would be backed up are moved, any that do not exist yet are skipped
+	filePaths, _, err := matchSaveFiles(logger, cfg)
+	if err != nil {
+		return asideDir, err
+	}
+	for _, filePath := range filePaths {
+		relPath, err := filepath.Rel(cfg.WorkingDir, filePath)
+		if err != nil {
+			return asideDir, err
+		}
+
+		asidePath := filepath.Join(asideDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(asidePath), 0o755); err != nil {
+			return asideDir, err
+		}
+		if err := os.Rename(filePath, asidePath); err != nil {
+			return asideDir, err
+		}
+	}
+	return asideDir, nil
+}
+
+// isAside reports whether the path is within a folder save files were moved into before a restore,
+// or staged in during one
+func isAside(relPath string) bool {
+	folderName := strings.SplitN(relPath, "/", 2)[0]
+	if strings.HasPrefix(folderName, stagingFolderPrefix) {
+		return true
+	} else if len(folderName) < len(asideFolderFormat) {
+		return false
+	} else if _, err := time.Parse(asideFolderFormat, folderName[:len(asideFolderFormat)]); err != nil {
+		return false
+	}
+
+	// Any suffix is the count added when restored more than once in a second
+	suffix := folderName[len(asideFolderFormat):]
+	if suffix == "" {
+		return true
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+	return strings.HasPrefix(suffix, "-") && err == nil
+}
+
+// portsInUse reports whether any of the ports are already bound, as they would be by a running game server
+func portsInUse(ports []int32) bool {
+	for _, port := range ports {
+		address := fmt.Sprintf(":%d", port)
+
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return true
+		}
+		l.Close()
+
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return true
+		}
+		conn.Close()
+	}
+	return false
+}
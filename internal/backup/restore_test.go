@@ -0,0 +1,238 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+	"game-server/internal/gamelock"
+)
+
+func Test_Client_Restore(t *testing.T) {
+	date := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)
+	prefix := "Game/2023-04-05/"
+
+	// Hold a port as a running game would
+	l, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer l.Close()
+	usedPort := l.Addr().(*net.TCPAddr).Port
+
+	hash := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name        string
+		ports       []int
+		keys        []string
+		manifest    *Manifest
+		locked      bool
+		expRestored map[string]string
+		expErr      bool
+		expRunning  bool
+	}{
+		{
+			name: "Happy path",
+			keys: []string{prefix + "world/level.dat", prefix + "world/region/r.0.0"},
+			expRestored: map[string]string{
+				"world/level.dat":    "restored world/level.dat",
+				"world/region/r.0.0": "restored world/region/r.0.0",
+			},
+		},
+		{
+			name:   "Sad path - No backup",
+			expErr: true,
+		},
+		{
+			name:     "Sad path - File outside working directory",
+			manifest: &Manifest{Format: config.BackupFiles, Files: []ManifestFile{{Path: "../escape"}}},
+			expErr:   true,
+		},
+		{
+			name: "Sad path - Checksum mismatch",
+			keys: []string{prefix + "world/level.dat", prefix + "world/region/r.0.0"},
+			manifest: &Manifest{
+				Format: config.BackupFiles,
+				Files: []ManifestFile{
+					{Path: "world/level.dat", SHA256: hash("restored world/level.dat")},
+					{Path: "world/region/r.0.0", SHA256: hash("corrupted")},
+				},
+			},
+			expErr: true,
+		},
+		{
+			name:       "Sad path - Game running",
+			ports:      []int{usedPort},
+			expErr:     true,
+			expRunning: true,
+		},
+		{
+			name:       "Sad path - Game running without ports",
+			keys:       []string{prefix + "world/level.dat"},
+			locked:     true,
+			expErr:     true,
+			expRunning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Current save in a working directory of its own
+			workingDir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(workingDir, "world"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(workingDir, "world", "level.dat"), []byte("current"), 0o644))
+
+			ports, err := json.Marshal(tt.ports)
+			require.NoError(t, err)
+			cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+				`[{"name": "Game", "working_directory": %q, "ports": %s, "save_files": ["world"]}]`, workingDir, ports,
+			)))
+			c := newTestClient(t, cfg)
+
+			for _, key := range tt.keys {
+				body := strings.NewReader("restored " + strings.TrimPrefix(key, prefix))
+				require.NoError(t, c.storage.Put(body, c.bucket, key))
+			}
+			if tt.manifest != nil {
+				require.NoError(t, c.putManifest(strings.TrimSuffix(prefix, "/"), tt.manifest))
+			}
+
+			// Lock the working directory as the service does for a running game
+			if tt.locked {
+				lock, err := gamelock.Acquire(workingDir, "Game")
+				require.NoError(t, err)
+				defer lock.Release()
+			}
+
+			err = c.Restore("game", date)
+
+			if tt.expErr {
+				require.Error(t, err)
+				if tt.expRunning {
+					assert.ErrorAs(t, err, &GameRunningError{})
+				}
+
+				// Current save is left in place
+				data, err := os.ReadFile(filepath.Join(workingDir, "world", "level.dat"))
+				require.NoError(t, err)
+				assert.Equal(t, "current", string(data))
+
+				// Nothing restored is left behind
+				assert.NoFileExists(t, filepath.Join(workingDir, "world", "region", "r.0.0"))
+				for _, pattern := range []string{"pre-restore-*", stagingFolderPrefix + "*"} {
+					leftover, err := filepath.Glob(filepath.Join(workingDir, pattern))
+					require.NoError(t, err)
+					assert.Empty(t, leftover)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			for filePath, expData := range tt.expRestored {
+				data, err := os.ReadFile(filepath.Join(workingDir, filepath.FromSlash(filePath)))
+				require.NoError(t, err)
+				assert.Equal(t, expData, string(data))
+			}
+
+			// Current save is moved aside
+			aside, err := filepath.Glob(filepath.Join(workingDir, "pre-restore-*", "world", "level.dat"))
+			require.NoError(t, err)
+			require.Len(t, aside, 1)
+			data, err := os.ReadFile(aside[0])
+			require.NoError(t, err)
+			assert.Equal(t, "current", string(data))
+		})
+	}
+}
+
+func Test_writeFileChecked(t *testing.T) {
+	workingDir := t.TempDir()
+	filePath := filepath.Join(workingDir, "world", "level.dat")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+	require.NoError(t, os.WriteFile(filePath, []byte("current"), 0o644))
+	sum := sha256.Sum256([]byte("restored"))
+
+	// File that does not match is never put in place
+	err := writeFileChecked(filePath, strings.NewReader("corrupted"), hex.EncodeToString(sum[:]))
+	assert.Error(t, err)
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "current", string(data))
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, writeFileChecked(filePath, strings.NewReader("restored"), hex.EncodeToString(sum[:])))
+	data, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "restored", string(data))
+}
+
+func Test_moveAside(t *testing.T) {
+	workingDir := t.TempDir()
+	for _, filePath := range []string{"world/level.dat", "world/cache/chunk.tmp", "server.log"} {
+		filePath = filepath.Join(workingDir, filepath.FromSlash(filePath))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+		require.NoError(t, os.WriteFile(filePath, []byte("data"), 0o644))
+	}
+	gameCfg := &config.GameConfig{
+		Name:       "Game",
+		WorkingDir: workingDir,
+		SaveFiles:  []string{"world", "players/*.dat"}, // Players are yet to join
+		Exclude:    []string{"**/cache"},
+	}
+
+	asideDir, err := moveAside(config.NewTestLogger(), gameCfg, time.Now())
+	require.NoError(t, err)
+
+	// Only the files that would be backed up are moved
+	assert.FileExists(t, filepath.Join(asideDir, "world", "level.dat"))
+	assert.NoFileExists(t, filepath.Join(workingDir, "world", "level.dat"))
+	assert.FileExists(t, filepath.Join(workingDir, "world", "cache", "chunk.tmp"))
+	assert.FileExists(t, filepath.Join(workingDir, "server.log"))
+}
+
+func Test_moveAside_MatchesEverything(t *testing.T) {
+	workingDir := t.TempDir()
+	levelPath := filepath.Join(workingDir, "world", "level.dat")
+	gameCfg := &config.GameConfig{
+		Name:       "Game",
+		WorkingDir: workingDir,
+		SaveFiles:  []string{"**"},
+	}
+	writeLevel := func() {
+		require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+		require.NoError(t, os.WriteFile(levelPath, []byte("data"), 0o644))
+	}
+
+	// Files moved aside by an earlier restore are left where they are
+	writeLevel()
+	now := time.Now()
+	firstDir, err := moveAside(config.NewTestLogger(), gameCfg, now)
+	require.NoError(t, err)
+	writeLevel()
+	secondDir, err := moveAside(config.NewTestLogger(), gameCfg, now)
+	require.NoError(t, err)
+	assert.Equal(t, firstDir+"-1", secondDir)
+	assert.FileExists(t, filepath.Join(firstDir, "world", "level.dat"))
+	assert.FileExists(t, filepath.Join(secondDir, "world", "level.dat"))
+
+	// Nor are they backed up
+	writeLevel()
+	filePaths, _, err := matchSaveFiles(config.NewTestLogger(), gameCfg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/world/level.dat": levelPath}, filePaths)
+}
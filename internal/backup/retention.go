@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/config"
+	"game-server/pkg/aws/s3"
+)
+
+// prune deletes the backups of a game no longer kept by its retention, along with any blobs only they referenced,
+// only logging them on a dry run
+func (c *Client) prune(gameCfg *config.GameConfig) error {
+	retention := c.cfg.GetRetention(gameCfg.Name)
+	if !retention.IsSet() {
+		return nil
+	}
+
+	// Group backed up files by date
+	prefix := gameCfg.Name + s3.Delimiter
+	keys, err := c.storage.List(c.bucket, prefix)
+	if err != nil {
+		return err
+	}
+	var dates []time.Time
+	var blobKeys []string
+	dateKeys := make(map[string][]string)
+	for _, key := range keys {
+		folder := strings.SplitN(strings.TrimPrefix(key, prefix), s3.Delimiter, 2)[0]
+		if folder == blobFolder {
+			blobKeys = append(blobKeys, key)
+			continue
+		}
+		date, err := time.Parse(DateFormat, folder)
+		if err != nil {
+			continue
+		}
+		if _, ok := dateKeys[folder]; !ok {
+			dates = append(dates, date)
+		}
+		dateKeys[folder] = append(dateKeys[folder], key)
+	}
+
+	dryRun := c.cfg.Settings.PruneDryRun
+	var expiredKeys []string
+	for _, date := range expiredDates(dates, retention) {
+		dateFolder := date.Format(DateFormat)
+		expiredKeys = append(expiredKeys, dateKeys[dateFolder]...)
+		c.logger.Info(
+			"pruning backup",
+			zap.String("game", gameCfg.Name),
+			zap.String("date", dateFolder),
+			zap.Int("files", len(dateKeys[dateFolder])),
+			zap.Bool("dryRun", dryRun),
+		)
+		delete(dateKeys, dateFolder)
+	}
+
+	// Only the latest complete backup of each kept date is needed
+	var manifestKeys []string
+	for dateFolder, keys := range dateKeys {
+		r := groupRuns(path.Join(prefix, dateFolder), keys)
+		if superseded := r.superseded(); len(superseded) > 0 {
+			expiredKeys = append(expiredKeys, superseded...)
+			c.logger.Info(
+				"pruning superseded backups",
+				zap.String("game", gameCfg.Name),
+				zap.String("date", dateFolder),
+				zap.Int("files", len(superseded)),
+				zap.Bool("dryRun", dryRun),
+			)
+		}
+
+		if latest := r.latestComplete(); latest != "" {
+			manifestKeys = append(manifestKeys, path.Join(prefix, dateFolder, latest, manifestName))
+		} else if manifestKey := path.Join(prefix, dateFolder, manifestName); hasKey(keys, manifestKey) {
+			manifestKeys = append(manifestKeys, manifestKey)
+		}
+	}
+
+	// Blobs are garbage once no kept backup references them
+	if len(blobKeys) > 0 {
+		garbage, err := c.unreferencedBlobs(gameCfg.Name, blobKeys, manifestKeys)
+		if err != nil {
+			return err
+		}
+		if len(garbage) > 0 {
+			c.logger.Info("pruning unreferenced blobs", zap.String("game", gameCfg.Name), zap.Int("blobs", len(garbage)), zap.Bool("dryRun", dryRun))
+		}
+		expiredKeys = append(expiredKeys, garbage...)
+	}
+
+	if dryRun || len(expiredKeys) == 0 {
+		return nil
+	}
+	return c.storage.DeleteObjects(c.bucket, expiredKeys)
+}
+
+// expiredDates gets the backup dates not kept by the retention, newest first
+func expiredDates(dates []time.Time, retention config.Retention) []time.Time {
+	sorted := make([]time.Time, len(dates))
+	copy(sorted, dates)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].After(sorted[j]) })
+
+	// Keep the latest backup in each of the most recent periods
+	kept := make(map[time.Time]struct{})
+	keepLatest := func(count int, period func(time.Time) string) {
+		periods := make(map[string]struct{})
+		for _, date := range sorted {
+			if len(periods) >= count {
+				return
+			}
+			if _, ok := periods[period(date)]; !ok {
+				periods[period(date)] = struct{}{}
+				kept[date] = struct{}{}
+			}
+		}
+	}
+	keepLatest(retention.Daily, func(date time.Time) string {
+		return date.Format(DateFormat)
+	})
+	keepLatest(retention.Weekly, func(date time.Time) string {
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepLatest(retention.Monthly, func(date time.Time) string {
+		return date.Format("2006-01")
+	})
+
+	var expired []time.Time
+	for _, date := range sorted {
+		if _, ok := kept[date]; !ok {
+			expired = append(expired, date)
+		}
+	}
+	return expired
+}
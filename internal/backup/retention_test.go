@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+)
+
+func Test_expiredDates(t *testing.T) {
+	// Daily backups from Sunday 2023-01-01 through Tuesday 2023-03-07
+	var dates []time.Time
+	for date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC); date.Month() < 3 || date.Day() <= 7; date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date)
+	}
+
+	tests := []struct {
+		name      string
+		retention config.Retention
+		expKept   []string
+	}{
+		{
+			name:      "Happy path - Daily",
+			retention: config.Retention{Daily: 3},
+			expKept:   []string{"2023-03-07", "2023-03-06", "2023-03-05"},
+		},
+		{
+			name:      "Happy path - Weekly",
+			retention: config.Retention{Weekly: 3},
+			expKept:   []string{"2023-03-07", "2023-03-05", "2023-02-26"},
+		},
+		{
+			name:      "Happy path - Monthly",
+			retention: config.Retention{Monthly: 2},
+			expKept:   []string{"2023-03-07", "2023-02-28"},
+		},
+		{
+			name:      "Happy path - Grandfather-father-son",
+			retention: config.Retention{Daily: 2, Weekly: 2, Monthly: 3},
+			expKept:   []string{"2023-03-07", "2023-03-06", "2023-03-05", "2023-02-28", "2023-01-31"},
+		},
+		{
+			name:      "Happy path - More kept than backed up",
+			retention: config.Retention{Daily: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired := expiredDates(dates, tt.retention)
+			if tt.expKept == nil {
+				assert.Empty(t, expired)
+				return
+			}
+
+			expiredSet := make(map[string]struct{}, len(expired))
+			for _, date := range expired {
+				expiredSet[date.Format(DateFormat)] = struct{}{}
+			}
+
+			var kept []string
+			for _, date := range dates {
+				if _, ok := expiredSet[date.Format(DateFormat)]; !ok {
+					kept = append(kept, date.Format(DateFormat))
+				}
+			}
+			assert.ElementsMatch(t, tt.expKept, kept)
+			assert.Len(t, expired, len(dates)-len(tt.expKept))
+		})
+	}
+}
+
+func Test_Client_prune(t *testing.T) {
+	gameName := "Game"
+
+	keys := []string{
+		"Game/2023-03-01/world/level.dat",
+		"Game/2023-03-01/world/region/r.0.0",
+		"Game/2023-03-02/world/level.dat",
+		"Game/2023-03-03/world/level.dat",
+		"Game/notadate/world/level.dat",
+	}
+
+	tests := []struct {
+		name       string
+		config     string
+		expDeleted []string
+	}{
+		{
+			name:       "Happy path",
+			config:     `{"retention": {"daily": 2}, "games": [{"name": "Game"}]}`,
+			expDeleted: []string{"Game/2023-03-01/world/level.dat", "Game/2023-03-01/world/region/r.0.0"},
+		},
+		{
+			name:   "Happy path - Dry run",
+			config: `{"retention": {"daily": 2}, "prune_dry_run": true, "games": [{"name": "Game"}]}`,
+		},
+		{
+			name:   "Happy path - Game keeps more",
+			config: `{"retention": {"daily": 2}, "games": [{"name": "Game", "retention": {"daily": 3}}]}`,
+		},
+		{
+			name:   "Happy path - No retention",
+			config: `{"games": [{"name": "Game"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewTestConfig(t, []byte(tt.config))
+			gameCfg, _ := cfg.GetGameConfig(gameName)
+
+			c := newTestClient(t, cfg)
+			for _, key := range keys {
+				require.NoError(t, c.storage.Put(strings.NewReader(key), c.bucket, key))
+			}
+
+			require.NoError(t, c.prune(gameCfg))
+
+			remaining, err := c.storage.List(c.bucket, fmt.Sprintf("%s/", gameName))
+			require.NoError(t, err)
+			var expRemaining []string
+			for _, key := range keys {
+				if !hasKey(tt.expDeleted, key) {
+					expRemaining = append(expRemaining, key)
+				}
+			}
+			assert.Equal(t, expRemaining, remaining)
+		})
+	}
+}
+
+func Test_Client_prune_Runs(t *testing.T) {
+	date := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	older := runFolder("Game", date.Add(time.Hour))
+	latest := runFolder("Game", date.Add(2*time.Hour))
+	inProgress := runFolder("Game", date.Add(3*time.Hour))
+	keys := []string{
+		"Game/2023-03-01/world/level.dat",
+		older + "/" + manifestName,
+		older + "/world/level.dat",
+		latest + "/" + manifestName,
+		latest + "/world/level.dat",
+		inProgress + "/world/level.dat",
+	}
+
+	cfg := config.NewTestConfig(t, []byte(`{"retention": {"daily": 1}, "games": [{"name": "Game"}]}`))
+	gameCfg, _ := cfg.GetGameConfig("Game")
+	c := newTestClient(t, cfg)
+	for _, key := range keys {
+		require.NoError(t, c.storage.Put(strings.NewReader("{}"), c.bucket, key))
+	}
+
+	require.NoError(t, c.prune(gameCfg))
+
+	// Only the latest complete run is kept, along with any run that may still be in progress
+	remaining, err := c.storage.List(c.bucket, "Game/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		latest + "/" + manifestName,
+		latest + "/world/level.dat",
+		inProgress + "/world/level.dat",
+	}, remaining)
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"game-server/pkg/aws/s3"
+)
+
+// Each backup run is written to a folder of its own within the date folder, so a run that fails part way
+// never overwrites an earlier backup from the same day
+const (
+	runPrefix = "run-"
+	runFormat = "150405.000"
+)
+
+// runFolder gets the folder a backup run started at the given time is written to
+func runFolder(game string, now time.Time) string {
+	return path.Join(game, now.Format(DateFormat), runPrefix+now.Format(runFormat))
+}
+
+// dateRuns are the keys of the backups of a game from a single date
+type dateRuns struct {
+	prefix string              // Date folder, ending with delimiter
+	root   []string            // Keys of a backup written directly to the date folder, before runs had their own
+	runs   map[string][]string // Keys of each run by its folder name
+}
+
+func groupRuns(dateFolder string, keys []string) dateRuns {
+	r := dateRuns{
+		prefix: dateFolder + s3.Delimiter,
+		runs:   make(map[string][]string),
+	}
+	for _, key := range keys {
+		parts := strings.SplitN(strings.TrimPrefix(key, r.prefix), s3.Delimiter, 2)
+		if len(parts) == 2 && isRun(parts[0]) {
+			r.runs[parts[0]] = append(r.runs[parts[0]], key)
+			continue
+		}
+		r.root = append(r.root, key)
+	}
+	return r
+}
+
+// runTime gets when the run in the date folder was started
+func runTime(dateFolderName string, runName string) (time.Time, error) {
+	return time.ParseInLocation(
+		DateFormat+" "+runFormat, dateFolderName+" "+strings.TrimPrefix(runName, runPrefix), time.Local,
+	)
+}
+
+func isRun(folderName string) bool {
+	if !strings.HasPrefix(folderName, runPrefix) {
+		return false
+	}
+	_, err := time.Parse(runFormat, strings.TrimPrefix(folderName, runPrefix))
+	return err == nil
+}
+
+// latestComplete gets the name of the latest run that wrote its manifest, empty if none did
+func (r dateRuns) latestComplete() string {
+	names := make([]string, 0, len(r.runs))
+	for name := range r.runs {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	for _, name := range names {
+		if hasKey(r.runs[name], r.prefix+name+s3.Delimiter+manifestName) {
+			return name
+		}
+	}
+	return ""
+}
+
+// superseded gets the keys of the backups older than the latest complete run, which are no longer needed,
+// leaving any later runs that may still be in progress
+func (r dateRuns) superseded() []string {
+	latest := r.latestComplete()
+	if latest == "" {
+		return nil
+	}
+
+	keys := append([]string(nil), r.root...)
+	for name, runKeys := range r.runs {
+		if name < latest {
+			keys = append(keys, runKeys...)
+		}
+	}
+	return keys
+}
+
+// lastBackupOn gets when the latest complete backup of a game from the date was started, if it has one.
+// Backups written directly to the date folder are only known by their date, so count until the next is due.
+func (c *Client) lastBackupOn(game string, dateFolderName string) (time.Time, bool, error) {
+	folder := path.Join(game, dateFolderName)
+	keys, err := c.storage.List(c.bucket, folder+s3.Delimiter)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	r := groupRuns(folder, keys)
+	if latest := r.latestComplete(); latest != "" {
+		started, err := runTime(dateFolderName, latest)
+		return started, err == nil, err
+	} else if len(r.root) > 0 {
+		date, err := time.Parse(DateFormat, dateFolderName)
+		return date.Add(Frequency), err == nil, err
+	}
+	return time.Time{}, false, nil
+}
+
+// getBackup gets the folder, keys and manifest of the latest complete backup of a game from the date,
+// the manifest is nil for files backed up before they had one
+func (c *Client) getBackup(game string, dateFolder string) (string, []string, *Manifest, error) {
+	folder := path.Join(game, dateFolder)
+	keys, err := c.storage.List(c.bucket, folder+s3.Delimiter)
+	if err != nil {
+		return "", nil, nil, err
+	} else if len(keys) == 0 {
+		return "", nil, nil, NoBackupError{Game: game, Date: dateFolder}
+	}
+
+	r := groupRuns(folder, keys)
+	if latest := r.latestComplete(); latest != "" {
+		folder = path.Join(folder, latest)
+		keys = r.runs[latest]
+	} else if len(r.root) > 0 {
+		keys = r.root
+	} else {
+		return "", nil, nil, fmt.Errorf("no complete backup of %s found for %s", game, dateFolder)
+	}
+
+	manifest, err := c.getLayout(folder, keys)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return folder, keys, manifest, nil
+}
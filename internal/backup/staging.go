@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Directory save files are copied to before upload, the system temp directory if not set.
+	// Copies can only share data with the save files when on the same filesystem
+	EnvStagingDir = "BACKUP_STAGING_DIR"
+
+	// Attempts at copying a save file before giving up on it changing
+	stageAttempts = 3
+)
+
+var (
+	// StageRetryDelay is the wait before copying a save file again, after it changed while being copied
+	StageRetryDelay = time.Second
+
+	// Replaced in tests to change save files mid copy
+	copySaveFile = copyFile
+)
+
+// snapshot is a copy of the save files of a game, staged in a temporary directory so they are
+// not changed by the game while uploading
+type snapshot struct {
+	dir   string
+	files map[string]io.ReadSeekCloser // Keyed by path within the backup
+}
+
+// takeSnapshot copies each save file into the staging directory, retrying any that changed while being copied
+func takeSnapshot(filePaths map[string]string) (*snapshot, error) {
+	dir, err := os.MkdirTemp(os.Getenv(EnvStagingDir), "save-snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	snap := &snapshot{
+		dir:   dir,
+		files: make(map[string]io.ReadSeekCloser, len(filePaths)),
+	}
+
+	for saveFilePath, filePath := range filePaths {
+		stagedPath := filepath.Join(dir, filepath.FromSlash(saveFilePath))
+		if err := stageFile(filePath, stagedPath); err != nil {
+			snap.Close()
+			return nil, err
+		}
+
+		f, err := os.Open(stagedPath)
+		if err != nil {
+			snap.Close()
+			return nil, err
+		}
+		snap.files[saveFilePath] = f
+	}
+	return snap, nil
+}
+
+// Close closes the staged files and removes the staging directory
+func (s *snapshot) Close() error {
+	for _, f := range s.files {
+		f.Close()
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// stageFile copies the file, checking its size and modified time are the same before and after
+func stageFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	for attempt := 1; attempt <= stageAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(StageRetryDelay)
+		}
+
+		before, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if err := copySaveFile(src, dst); err != nil {
+			return err
+		}
+		after, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+
+		if unchanged(before, after) {
+			return nil
+		}
+	}
+	return fmt.Errorf("save file kept changing while being copied: [%s]", src)
+}
+
+func unchanged(before fs.FileInfo, after fs.FileInfo) bool {
+	return before.Size() == after.Size() && before.ModTime().Equal(after.ModTime())
+}
+
+// copyFile copies the file, sharing its data rather than copying where the filesystem supports it
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := reflink(out, in); err != nil {
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// cancelableReader stops reading once the context is done
+type cancelableReader struct {
+	io.Reader
+	ctx context.Context
+}
+
+func (r cancelableReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// cancelableFile stops reading a staged file once the context is done
+type cancelableFile struct {
+	io.ReadSeekCloser
+	ctx context.Context
+}
+
+func (f cancelableFile) Read(p []byte) (int, error) {
+	return cancelableReader{Reader: f.ReadSeekCloser, ctx: f.ctx}.Read(p)
+}
@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_takeSnapshot(t *testing.T) {
+	// Override retry delay
+	defer func(origDelay time.Duration) {
+		StageRetryDelay = origDelay
+	}(StageRetryDelay)
+	StageRetryDelay = 0
+
+	tests := []struct {
+		name    string
+		changes int // Times the save file is written to while being copied
+		expData string
+		expErr  bool
+	}{
+		{
+			name:    "Happy path",
+			expData: "level data",
+		},
+		{
+			name:    "Happy path - Changed while copying",
+			changes: 1,
+			expData: "level data, more level data",
+		},
+		{
+			name:    "Sad path - Kept changing",
+			changes: stageAttempts,
+			expErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stagingDir := t.TempDir()
+			t.Setenv(EnvStagingDir, stagingDir)
+
+			levelPath := filepath.Join(t.TempDir(), "level.dat")
+			require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
+
+			// Have the game write to its save as it is copied
+			defer func(origCopy func(string, string) error) {
+				copySaveFile = origCopy
+			}(copySaveFile)
+			changes := 0
+			copySaveFile = func(src string, dst string) error {
+				err := copyFile(src, dst)
+				if changes < tt.changes {
+					changes++
+					f, err := os.OpenFile(src, os.O_APPEND|os.O_WRONLY, 0)
+					require.NoError(t, err)
+					_, err = f.WriteString(", more level data")
+					require.NoError(t, err)
+					require.NoError(t, f.Close())
+				}
+				return err
+			}
+
+			snap, err := takeSnapshot(map[string]string{"/world/level.dat": levelPath})
+
+			if tt.expErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				data, err := io.ReadAll(snap.files["/world/level.dat"])
+				require.NoError(t, err)
+				assert.Equal(t, tt.expData, string(data))
+				require.NoError(t, snap.Close())
+			}
+
+			// Staging directory is always cleaned up
+			staged, err := os.ReadDir(stagingDir)
+			require.NoError(t, err)
+			assert.Empty(t, staged)
+		})
+	}
+}
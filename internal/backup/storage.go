@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"io"
+	"net/url"
+	"os"
+
+	"game-server/internal/config"
+	"game-server/pkg/aws/s3"
+	customerrors "game-server/pkg/errors"
+	"game-server/pkg/filestore"
+)
+
+// Ensure storage backends implement Storage, and S3 can check files without downloading them
+var (
+	_ Storage     = (*s3.Client)(nil)
+	_ Storage     = (*filestore.Client)(nil)
+	_ checksummer = (*s3.Client)(nil)
+)
+
+// Storage is where backups are kept, where the bucket is an S3 bucket or the root directory of a local backend
+type Storage interface {
+	Connect() error
+	GetFolders(bucket string, depth int) ([]string, error)
+	Put(file io.ReadSeeker, bucket string, key string) error
+	Upload(body io.Reader, bucket string, key string) error
+	List(bucket string, prefix string) ([]string, error)
+	Get(bucket string, key string) (io.ReadCloser, error)
+	DeleteObjects(bucket string, keys []string) error
+}
+
+// newStorage gets the backend for the storage URL, which has been validated by the config
+func newStorage(storageURL string) Storage {
+	if u, err := url.Parse(storageURL); err == nil && u.Scheme == config.StorageFile {
+		return filestore.New()
+	}
+	return s3.New()
+}
+
+// getBucket gets the bucket from the storage URL, falling back to the env for S3
+func getBucket(storageURL string) (string, error) {
+	if storageURL == "" {
+		bucket, ok := os.LookupEnv(EnvGameSaveBucket)
+		if !ok {
+			return "", customerrors.MissingEnvErr{}
+		}
+		return bucket, nil
+	}
+
+	u, err := url.Parse(storageURL)
+	if err != nil {
+		return "", err
+	} else if u.Scheme == config.StorageFile {
+		return u.Path, nil
+	}
+	return u.Host, nil
+}
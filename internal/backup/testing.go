@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	DoBackupMethod    = "DoBackup"
+	ForceBackupMethod = "ForceBackup"
+	BackupGameMethod  = "BackupGame"
+	RestoreMethod     = "Restore"
+	VerifyMethod      = "Verify"
+)
+
+// Ensure MockClient implements ClientIFace
+var _ ClientIFace = (*MockClient)(nil)
+
+type MockClient struct {
+	mock.Mock
+}
+
+func (m *MockClient) DoBackup() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockClient) ForceBackup(games ...string) error {
+	args := m.Called(games)
+	return args.Error(0)
+}
+
+func (m *MockClient) BackupGame(ctx context.Context, game string) error {
+	args := m.Called(ctx, game)
+	return args.Error(0)
+}
+
+func (m *MockClient) Restore(game string, date time.Time) error {
+	args := m.Called(game, date)
+	return args.Error(0)
+}
+
+func (m *MockClient) Verify(ctx context.Context, game string, date time.Time) error {
+	args := m.Called(ctx, game, date)
+	return args.Error(0)
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/config"
+	"game-server/pkg/aws/s3"
+)
+
+// checksummer is storage that keeps the SHA-256 of some files, so they can be checked without downloading
+type checksummer interface {
+	Checksum(bucket string, key string) (string, error)
+}
+
+// VerifyError lists the files of a backup that do not match its manifest
+type VerifyError struct {
+	Game     string
+	Date     string
+	Failures []string
+}
+
+func (e VerifyError) Error() string {
+	return fmt.Sprintf("%s backup from %s failed verification: %s", e.Game, e.Date, strings.Join(e.Failures, "; "))
+}
+
+// NoBackupError is returned when a game has no backup at all, or none from the date if given
+type NoBackupError struct {
+	Game string
+	Date string
+}
+
+func (e NoBackupError) Error() string {
+	if e.Date == "" {
+		return fmt.Sprintf("no backup of %s found", e.Game)
+	}
+	return fmt.Sprintf("no backup of %s found for %s", e.Game, e.Date)
+}
+
+// NoManifestError is returned when verifying a backup made before backups had a manifest, which cannot be checked
+type NoManifestError struct {
+	Game string
+	Date string
+}
+
+func (e NoManifestError) Error() string {
+	return fmt.Sprintf("%s backup from %s has no manifest to verify against", e.Game, e.Date)
+}
+
+// Verify checks each file of the backup of a game from the given date, or its latest backup if zero,
+// against the hash recorded in its manifest, stopping once the context is done
+func (c *Client) Verify(ctx context.Context, game string, date time.Time) error {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
+	if !ok {
+		return fmt.Errorf("no configuration for game: [%s]", game)
+	}
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	if date.IsZero() {
+		var err error
+		if date, err = c.latestBackup(gameCfg.Name); err != nil {
+			return err
+		}
+	}
+
+	dateFolder := date.Format(DateFormat)
+	folder, _, manifest, err := c.getBackup(gameCfg.Name, dateFolder)
+	if err != nil {
+		return err
+	} else if manifest == nil {
+		return NoManifestError{Game: gameCfg.Name, Date: dateFolder}
+	} else if _, err := c.dataKey(manifest, ManifestFile{}); err != nil {
+		return fmt.Errorf("could not decrypt %s backup: %w", gameCfg.Name, err)
+	}
+
+	var failures []string
+	if manifest.Format == config.BackupTarGz {
+		err := c.readArchive(folder, manifest, func(file ManifestFile, r io.Reader) error {
+			_, err := io.Copy(io.Discard, cancelableReader{Reader: r, ctx: ctx})
+			return err
+		})
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	} else {
+		for i, key := range c.objectKeys(gameCfg.Name, folder, manifest) {
+			if ctx.Err() != nil {
+				break
+			}
+			file := manifest.Files[i]
+			if err := c.verifyObject(ctx, key, manifest, file); err != nil {
+				failures = append(failures, fmt.Sprintf("[%s] %s", file.Path, err))
+			}
+		}
+	}
+
+	// Files left unchecked are not failures
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.logger.Info(
+		"verified backup",
+		zap.String("game", gameCfg.Name),
+		zap.String("date", dateFolder),
+		zap.Int("files", len(manifest.Files)),
+		zap.Int("failures", len(failures)),
+	)
+	if len(failures) > 0 {
+		return VerifyError{Game: gameCfg.Name, Date: dateFolder, Failures: failures}
+	}
+	return nil
+}
+
+// verifyObject checks a file against the manifest, only downloading it if the storage has no checksum of it
+func (c *Client) verifyObject(ctx context.Context, key string, manifest *Manifest, file ManifestFile) error {
+	// Stored checksums of encrypted files are of their encrypted contents
+	if cs, ok := c.storage.(checksummer); ok && !manifest.Encrypted {
+		sum, err := cs.Checksum(c.bucket, key)
+		if err != nil {
+			return err
+		} else if sum != "" {
+			if sum != file.SHA256 {
+				return fmt.Errorf("checksum mismatch")
+			}
+			return nil
+		}
+	}
+
+	aead, err := c.dataKey(manifest, file)
+	if err != nil {
+		return err
+	}
+	body, err := c.storage.Get(c.bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	r, err := open(aead, body, manifest.Encrypted)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, cancelableReader{Reader: r, ctx: ctx})
+	if err != nil {
+		return err
+	} else if size != file.Size {
+		return fmt.Errorf("size mismatch, expected %d bytes but got %d", file.Size, size)
+	} else if hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+// latestBackup gets the date of the most recent backup of a game
+func (c *Client) latestBackup(game string) (time.Time, error) {
+	prefix := game + s3.Delimiter
+	keys, err := c.storage.List(c.bucket, prefix)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest time.Time
+	for _, key := range keys {
+		folder := strings.SplitN(strings.TrimPrefix(key, prefix), s3.Delimiter, 2)[0]
+		if date, err := time.Parse(DateFormat, folder); err == nil && date.After(latest) {
+			latest = date
+		}
+	}
+	if latest.IsZero() {
+		return time.Time{}, NoBackupError{Game: game}
+	}
+	return latest, nil
+}
@@ -0,0 +1,171 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/config"
+)
+
+func Test_Client_Verify(t *testing.T) {
+	today, _ := time.Parse(DateFormat, time.Now().Format(DateFormat))
+
+	tests := []struct {
+		name      string
+		format    string
+		date      time.Time
+		tamper    string // Key of the stored file to corrupt, within the backup folder
+		checksums bool
+		cancelled bool
+		expErr    bool
+	}{
+		{
+			name:   "Happy path - Files",
+			format: config.BackupFiles,
+			date:   today,
+		},
+		{
+			name:   "Happy path - Archive",
+			format: config.BackupTarGz,
+			date:   today,
+		},
+		{
+			name:   "Happy path - Incremental",
+			format: config.BackupIncremental,
+			date:   today,
+		},
+		{
+			name:   "Happy path - Latest backup",
+			format: config.BackupFiles,
+		},
+		{
+			name:      "Happy path - Stored checksums",
+			format:    config.BackupFiles,
+			date:      today,
+			checksums: true,
+		},
+		{
+			name:   "Sad path - Corrupt file",
+			format: config.BackupFiles,
+			date:   today,
+			tamper: "world/level.dat",
+			expErr: true,
+		},
+		{
+			name:   "Sad path - Corrupt archive",
+			format: config.BackupTarGz,
+			date:   today,
+			tamper: archiveName,
+			expErr: true,
+		},
+		{
+			name:      "Sad path - Corrupt file with stored checksums",
+			format:    config.BackupFiles,
+			date:      today,
+			tamper:    "world/level.dat",
+			checksums: true,
+			expErr:    true,
+		},
+		{
+			name:      "Sad path - Cancelled",
+			format:    config.BackupFiles,
+			date:      today,
+			cancelled: true,
+			expErr:    true,
+		},
+		{
+			name:   "Sad path - No backup",
+			format: config.BackupFiles,
+			date:   today.AddDate(0, 0, -1),
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			levelPath := filepath.Join(workingDir, "world", "level.dat")
+			require.NoError(t, os.MkdirAll(filepath.Dir(levelPath), 0o755))
+			require.NoError(t, os.WriteFile(levelPath, []byte("level data"), 0o644))
+
+			cfg := config.NewTestConfig(t, []byte(fmt.Sprintf(
+				`[{"name": "Game", "working_directory": %q, "save_files": ["world"], "backup_format": %q}]`, workingDir, tt.format,
+			)))
+			gameCfg, _ := cfg.GetGameConfig("Game")
+			c := newTestClient(t, cfg)
+			require.NoError(t, c.backupGame(context.Background(), gameCfg, time.Time{}))
+
+			if tt.tamper != "" {
+				folder, _, _, err := c.getBackup("Game", today.Format(DateFormat))
+				require.NoError(t, err)
+				require.NoError(t, c.storage.Put(strings.NewReader("corrupted"), c.bucket, path.Join(folder, tt.tamper)))
+			}
+
+			storage := &checksumStorage{Storage: c.storage}
+			if tt.checksums {
+				c.storage = storage
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelled {
+				cancel()
+			}
+			defer cancel()
+			err := c.Verify(ctx, "Game", tt.date)
+
+			if tt.expErr {
+				assert.Error(t, err)
+				if tt.tamper != "" {
+					assert.ErrorAs(t, err, &VerifyError{})
+				} else if tt.cancelled {
+					assert.ErrorIs(t, err, context.Canceled)
+				} else {
+					assert.ErrorAs(t, err, &NoBackupError{})
+				}
+				return
+			}
+			require.NoError(t, err)
+			if tt.checksums {
+				// Only the manifest is downloaded
+				assert.Equal(t, 1, storage.gets)
+			}
+		})
+	}
+}
+
+// checksumStorage reports the checksum of stored files like S3, counting only downloads made by the client
+type checksumStorage struct {
+	Storage
+
+	gets int
+}
+
+func (s *checksumStorage) Checksum(bucket string, key string) (string, error) {
+	body, err := s.Storage.Get(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *checksumStorage) Get(bucket string, key string) (io.ReadCloser, error) {
+	s.gets++
+	return s.Storage.Get(bucket, key)
+}
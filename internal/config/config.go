@@ -1,21 +1,101 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"go.uber.org/zap"
+
+	"game-server/pkg/query"
 )
 
 const (
 	EnvGameConfig = "GAME_CONFIG_PATH"
+
+	// Restart policies
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+
+	// Inactivity monitors
+	MonitorPackets   = "packets"
+	MonitorPlayers   = "players"
+	MonitorComposite = "composite"
+
+	// Command transports
+	TransportStdin = "stdin"
+	TransportRCON  = "rcon"
+
+	// Backup formats
+	BackupFiles       = "files"
+	BackupTarGz       = "tar.gz"
+	BackupIncremental = "incremental"
+
+	// Backup storage URL schemes
+	StorageS3   = "s3"
+	StorageFile = "file"
 )
 
 type Config struct {
-	Logger *zap.Logger
-	games  map[string]*GameConfig
+	Logger   *zap.Logger
+	Settings Settings
+	games    map[string]*GameConfig
+}
+
+// Settings apply to the service as a whole rather than a single game
+type Settings struct {
+	MaxRunningGames   int       `json:"max_running_games"`  // Unlimited if zero
+	InactivityMonitor string    `json:"inactivity_monitor"` // Packets if empty
+	ShutdownWarning   Duration  `json:"shutdown_warning"`   // Service default if zero, games may set their own
+	MaxExtension      Duration  `json:"max_extension"`      // Service default if zero
+	StopInstance      bool      `json:"stop_instance"`      // Stop the host instance after shutdown
+	SpotInterruption  bool      `json:"spot_interruption"`  // Watch for the host spot instance being reclaimed
+	Retention         Retention `json:"retention"`          // Backups are kept forever if not set, games may set their own
+	PruneDryRun       bool      `json:"prune_dry_run"`      // Only log the backups that would be pruned
+	BackupStorage     string    `json:"backup_storage"`     // s3://bucket without a prefix or file:///path, S3 bucket from env if empty
+	VerifyInterval    Duration  `json:"verify_interval"`    // Latest backups are never verified while serving if zero
+}
+
+// Retention is the number of backups kept for each period, grandfather-father-son style,
+// where the latest backup of each day, week or month is kept
+type Retention struct {
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// IsSet reports whether any backups are to be pruned
+func (r Retention) IsSet() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+func (r Retention) validate() error {
+	if r.Daily < 0 || r.Weekly < 0 || r.Monthly < 0 {
+		return fmt.Errorf("retention counts cannot be negative")
+	}
+	return nil
+}
+
+// SessionBackup backs up a game while it is running, having it write its save with console commands first
+type SessionBackup struct {
+	Interval       Duration `json:"interval"`        // No backups while running if zero
+	PauseAutosave  string   `json:"pause_autosave"`  // Command to stop the game writing its save, such as save-off
+	Save           string   `json:"save"`            // Command to write the save, such as save-all
+	SavedPattern   string   `json:"saved_pattern"`   // Output once the save is written, not awaited if empty
+	SavedTimeout   Duration `json:"saved_timeout"`   // Service default if zero
+	ResumeAutosave string   `json:"resume_autosave"` // Command to start writing the save again, such as save-on
+}
+
+// configFile is the layout of a config file with settings, a file may also be just the list of games
+type configFile struct {
+	Settings
+	Games []*GameConfig `json:"games"`
 }
 
 type GameConfig struct {
@@ -26,10 +106,57 @@ type GameConfig struct {
 		Command string   `json:"command"`
 		Args    []string `json:"args"`
 	} `json:"run"`
-	Message   string   `json:"message"`
-	Stop      string   `json:"stop"`
-	Ports     []int32  `json:"ports"`
-	SaveFiles []string `json:"save_files"`
+	Message          string `json:"message"`
+	Stop             string `json:"stop"`
+	CommandTransport string `json:"command_transport"` // Stdin if empty
+	RCON             struct {
+		Port     int32  `json:"port"`
+		Password string `json:"password"`
+	} `json:"rcon"`
+	Ports                 []int32       `json:"ports"`
+	SaveFiles             []string      `json:"save_files"`    // Glob patterns, matched directories are included whole
+	Exclude               []string      `json:"exclude"`       // Glob patterns of save files and directories to leave out
+	MaxFileSize           int64         `json:"max_file_size"` // Bytes, larger save files are left out, no limit if zero
+	Retention             *Retention    `json:"retention"`     // Service retention if nil
+	BackupFormat          string        `json:"backup_format"` // Files if empty
+	SessionBackup         SessionBackup `json:"session_backup"`
+	ReadyPattern          string        `json:"ready_pattern"`
+	ReadyTimeout          Duration      `json:"ready_timeout"`
+	InactivityTimeout     Duration      `json:"inactivity_timeout"`      // Service default if zero
+	StartupGrace          Duration      `json:"startup_grace"`           // Time after starting before inactivity is counted
+	WarningBeforeShutdown Duration      `json:"warning_before_shutdown"` // Service warning if zero
+	Restart               struct {
+		Policy      string   `json:"policy"`
+		MaxAttempts int      `json:"max_attempts"`
+		Backoff     Duration `json:"backoff"`
+	} `json:"restart"`
+	Query struct {
+		Protocol string `json:"protocol"`
+		Port     int32  `json:"port"`
+	} `json:"query"`
+}
+
+// Duration allows durations to be written as strings in the config file, such as "5m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = dur
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
 }
 
 func New() *Config {
@@ -58,6 +185,14 @@ func (c *Config) GetGameConfig(game string) (*GameConfig, bool) {
 	return cfg, ok
 }
 
+// GetRetention gets the backup retention for a game, which falls back to the service retention
+func (c *Config) GetRetention(game string) Retention {
+	if gameCfg, ok := c.GetGameConfig(game); ok && gameCfg.Retention != nil {
+		return *gameCfg.Retention
+	}
+	return c.Settings.Retention
+}
+
 func (c *Config) GetGameNames() []string {
 	names := make([]string, 0, len(c.games))
 	for _, gameCfg := range c.games {
@@ -88,10 +223,19 @@ func (c *Config) loadGameConfig() error {
 }
 
 func (c *Config) loadGameConfigFile(fileData []byte) error {
-	var gameCfgs []*GameConfig
-	if err := json.Unmarshal(fileData, &gameCfgs); err != nil {
+	var file configFile
+	if trimmed := bytes.TrimSpace(fileData); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(fileData, &file.Games); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(fileData, &file); err != nil {
 		return err
 	}
+	if err := file.Settings.validate(); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	c.Settings = file.Settings
+	gameCfgs := file.Games
 
 	c.games = make(map[string]*GameConfig, len(gameCfgs))
 	for _, gameCfg := range gameCfgs {
@@ -99,8 +243,109 @@ func (c *Config) loadGameConfigFile(fileData []byte) error {
 		if _, ok := c.games[gameName]; ok {
 			return fmt.Errorf("multiple configs found for game: [%s]", gameCfg.Name)
 		}
+		if err := gameCfg.validate(); err != nil {
+			return fmt.Errorf("invalid config for game [%s]: %w", gameCfg.Name, err)
+		}
+
+		// Players of games without a query protocol could never be counted
+		if c.Settings.InactivityMonitor == MonitorPlayers && gameCfg.Query.Protocol == "" {
+			return fmt.Errorf("invalid config for game [%s]: players inactivity monitor requires a query protocol", gameCfg.Name)
+		}
 		c.games[gameName] = gameCfg
 	}
 
 	return nil
 }
+
+func (s *Settings) validate() error {
+	switch s.InactivityMonitor {
+	case "", MonitorPackets, MonitorPlayers, MonitorComposite:
+	default:
+		return fmt.Errorf("invalid inactivity monitor: [%s]", s.InactivityMonitor)
+	}
+
+	if s.BackupStorage != "" {
+		u, err := url.Parse(s.BackupStorage)
+		if err != nil {
+			return fmt.Errorf("invalid backup storage: %w", err)
+		}
+		switch {
+		case u.Scheme == StorageS3 && u.Host != "" && strings.Trim(u.Path, "/") != "":
+			// Backups are always kept at the root of the bucket
+			return fmt.Errorf("invalid backup storage, key prefixes are not supported: [%s]", s.BackupStorage)
+		case u.Scheme == StorageS3 && u.Host != "":
+		case u.Scheme == StorageFile && u.Path != "":
+		default:
+			return fmt.Errorf("invalid backup storage: [%s]", s.BackupStorage)
+		}
+	}
+
+	return s.Retention.validate()
+}
+
+func (g *GameConfig) validate() error {
+	if _, err := regexp.Compile(g.ReadyPattern); err != nil {
+		return fmt.Errorf("invalid ready pattern: %w", err)
+	}
+
+	switch g.Restart.Policy {
+	case "", RestartNever:
+	case RestartOnFailure:
+		// Would never restart otherwise
+		if g.Restart.MaxAttempts <= 0 {
+			return fmt.Errorf("restart on failure requires max attempts of at least 1")
+		}
+	default:
+		return fmt.Errorf("invalid restart policy: [%s]", g.Restart.Policy)
+	}
+
+	if timeout := g.InactivityTimeout.Duration; timeout > 0 && g.WarningBeforeShutdown.Duration >= timeout {
+		return fmt.Errorf("shutdown warning must be less than the inactivity timeout")
+	}
+
+	if g.Retention != nil {
+		if err := g.Retention.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, patterns := range [][]string{g.SaveFiles, g.Exclude} {
+		for _, pattern := range patterns {
+			if !doublestar.ValidatePattern(pattern) {
+				return fmt.Errorf("invalid save file pattern: [%s]", pattern)
+			}
+		}
+	}
+	if g.MaxFileSize < 0 {
+		return fmt.Errorf("max file size cannot be negative")
+	}
+
+	switch g.BackupFormat {
+	case "", BackupFiles, BackupTarGz, BackupIncremental:
+	default:
+		return fmt.Errorf("invalid backup format: [%s]", g.BackupFormat)
+	}
+
+	if _, err := regexp.Compile(g.SessionBackup.SavedPattern); err != nil {
+		return fmt.Errorf("invalid saved pattern: %w", err)
+	}
+
+	switch g.CommandTransport {
+	case "", TransportStdin:
+	case TransportRCON:
+		if g.RCON.Port == 0 {
+			return fmt.Errorf("missing rcon port")
+		}
+	default:
+		return fmt.Errorf("invalid command transport: [%s]", g.CommandTransport)
+	}
+
+	if protocol := g.Query.Protocol; protocol != "" {
+		if !query.IsSupported(protocol) {
+			return fmt.Errorf("invalid query protocol: [%s]", protocol)
+		} else if g.Query.Port == 0 {
+			return fmt.Errorf("missing query port")
+		}
+	}
+	return nil
+}
@@ -1,18 +1,149 @@
 package config_test
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"game-server/internal/config"
 )
 
 func Test_Load(t *testing.T) {
-	// Set game config path to mock
-	t.Setenv(config.EnvGameConfig, "testdata/emptyconfig.json")
+	tests := []struct {
+		name       string
+		configPath string
+		expErr     string
+	}{
+		{
+			name:       "Happy path",
+			configPath: "testdata/emptyconfig.json",
+		},
+		{
+			name:       "Sad path - Invalid ready pattern",
+			configPath: "testdata/invalidpattern.json",
+			expErr:     "invalid ready pattern",
+		},
+		{
+			name:       "Sad path - Invalid restart policy",
+			configPath: "testdata/invalidrestart.json",
+			expErr:     "invalid restart policy",
+		},
+		{
+			name:       "Sad path - Restart on failure without max attempts",
+			configPath: "testdata/invalidrestartattempts.json",
+			expErr:     "restart on failure requires max attempts of at least 1",
+		},
+		{
+			name:       "Sad path - Invalid query protocol",
+			configPath: "testdata/invalidquery.json",
+			expErr:     "invalid query protocol",
+		},
+		{
+			name:       "Sad path - RCON transport without port",
+			configPath: "testdata/invalidtransport.json",
+			expErr:     "missing rcon port",
+		},
+		{
+			name:       "Sad path - Shutdown warning not before timeout",
+			configPath: "testdata/invalidwarning.json",
+			expErr:     "shutdown warning must be less than the inactivity timeout",
+		},
+		{
+			name:       "Sad path - Invalid inactivity monitor",
+			configPath: "testdata/invalidmonitor.json",
+			expErr:     "invalid inactivity monitor",
+		},
+		{
+			name:       "Sad path - Players monitor without query protocol",
+			configPath: "testdata/invalidplayersmonitor.json",
+			expErr:     "players inactivity monitor requires a query protocol",
+		},
+		{
+			name:       "Sad path - Negative retention",
+			configPath: "testdata/invalidretention.json",
+			expErr:     "retention counts cannot be negative",
+		},
+		{
+			name:       "Sad path - Invalid backup format",
+			configPath: "testdata/invalidbackupformat.json",
+			expErr:     "invalid backup format",
+		},
+		{
+			name:       "Sad path - Invalid saved pattern",
+			configPath: "testdata/invalidsavedpattern.json",
+			expErr:     "invalid saved pattern",
+		},
+		{
+			name:       "Sad path - Invalid save file pattern",
+			configPath: "testdata/invalidsavefiles.json",
+			expErr:     "invalid save file pattern",
+		},
+		{
+			name:       "Sad path - Invalid backup storage",
+			configPath: "testdata/invalidstorage.json",
+			expErr:     "invalid backup storage",
+		},
+		{
+			name:       "Sad path - Backup storage with key prefix",
+			configPath: "testdata/invalidstorageprefix.json",
+			expErr:     "key prefixes are not supported",
+		},
+		{
+			name:       "Sad path - Invalid duration",
+			configPath: "testdata/invalidduration.json",
+			expErr:     "invalid duration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set game config path to mock
+			t.Setenv(config.EnvGameConfig, tt.configPath)
+
+			cfg := config.New()
+			err := cfg.Load()
+
+			if tt.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expErr)
+			}
+		})
+	}
+}
+
+func Test_Load_Settings(t *testing.T) {
+	t.Setenv(config.EnvGameConfig, "testdata/settingsconfig.json")
 
 	cfg := config.New()
+	require.NoError(t, cfg.Load())
+
+	assert.Equal(t, 2, cfg.Settings.MaxRunningGames)
+	assert.Equal(t, config.MonitorComposite, cfg.Settings.InactivityMonitor)
+	assert.Equal(t, 2*time.Minute, cfg.Settings.ShutdownWarning.Duration)
+	assert.Equal(t, 3*time.Hour, cfg.Settings.MaxExtension.Duration)
+	assert.True(t, cfg.Settings.StopInstance)
+	assert.True(t, cfg.Settings.SpotInterruption)
+	assert.True(t, cfg.Settings.PruneDryRun)
+	assert.Equal(t, "file:///mnt/nas/backups", cfg.Settings.BackupStorage)
+	assert.Equal(t, 24*time.Hour, cfg.Settings.VerifyInterval.Duration)
+	assert.Equal(t, config.Retention{Daily: 7, Weekly: 4, Monthly: 12}, cfg.GetRetention("GameOne"))
+	assert.Equal(t, config.Retention{Daily: 3}, cfg.GetRetention("GameTwo"))
+	assert.ElementsMatch(t, []string{"GameOne", "GameTwo"}, cfg.GetGameNames())
+	assert.ElementsMatch(t, []int32{27015, 25565}, cfg.GetGamePorts())
+}
+
+func Test_Duration(t *testing.T) {
+	var d config.Duration
+	require.NoError(t, json.Unmarshal([]byte(`"1m30s"`), &d))
+	assert.Equal(t, 90*time.Second, d.Duration)
+
+	out, err := json.Marshal(d)
+	require.NoError(t, err)
+	assert.Equal(t, `"1m30s"`, string(out))
 
-	assert.NoError(t, cfg.Load())
+	assert.Error(t, json.Unmarshal([]byte(`90`), &d))
 }
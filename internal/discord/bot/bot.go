@@ -9,11 +9,13 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
 
+	"game-server/internal/backup"
 	"game-server/internal/config"
 	"game-server/internal/discord/command"
 	"game-server/internal/gameserver"
@@ -29,10 +31,29 @@ const (
 
 	loggerName = "discord-bot"
 
-	port        = "8080"
-	BotEndpoint = "/discord"
+	port = "8080"
 )
 
+// Inactivity extension given by the button on a shutdown warning
+var ButtonExtension = 30 * time.Minute
+
+// Ensure BotServer implements ServerIFace
+var _ ServerIFace = (*BotServer)(nil)
+
+// Extender is the inactivity monitor, as far as the bot needs it
+type Extender interface {
+	Extend(extension time.Duration) time.Duration
+}
+
+type ServerIFace interface {
+	SetMonitor(m Extender)
+	Connect() error
+	Run() error
+	Stop() error
+	ShutdownPending(remaining time.Duration)
+	Announce(msg string)
+}
+
 type BotServer struct {
 	srv    *http.Server
 	logger *zap.Logger
@@ -43,6 +64,8 @@ type BotServer struct {
 	sqsUrl    string
 
 	gameClient gameserver.ClientIFace
+	monitor    Extender
+	backup     backup.ClientIFace
 
 	discordSession discord.SessionIFace
 	channelId      string
@@ -51,16 +74,17 @@ type BotServer struct {
 	sqsClient sqs.ClientIFace
 }
 
-func New(cfg *config.Config, gameClient gameserver.ClientIFace) *BotServer {
+func New(cfg *config.Config, gameClient gameserver.ClientIFace, backupClient backup.ClientIFace) *BotServer {
 	botServer := &BotServer{
 		logger:     cfg.Logger.Named(loggerName),
 		gameClient: gameClient,
+		backup:     backupClient,
 		sqsClient:  sqs.New(),
 	}
 
 	// Configure server multiplexer
 	mux := http.NewServeMux()
-	mux.HandleFunc(BotEndpoint, botServer.eventHandler)
+	mux.HandleFunc(command.BotEndpoint, botServer.eventHandler)
 
 	botServer.srv = &http.Server{
 		Addr:    fmt.Sprintf(":%s", port),
@@ -70,6 +94,11 @@ func New(cfg *config.Config, gameClient gameserver.ClientIFace) *BotServer {
 	return botServer
 }
 
+// SetMonitor sets the inactivity monitor that can be extended from the channel
+func (b *BotServer) SetMonitor(m Extender) {
+	b.monitor = m
+}
+
 func (b *BotServer) Connect() error {
 	// Get expected env variables
 	if err := b.loadEnv(); err != nil {
@@ -88,6 +117,9 @@ func (b *BotServer) Connect() error {
 }
 
 func (b *BotServer) Run() error {
+	// Relay game server events to the channel
+	go b.relayEvents(b.gameClient.Subscribe())
+
 	// Handle any queued messages
 	b.logger.Info("checking deferred message queue")
 	if err := b.checkMessageQueue(); err != nil {
@@ -194,11 +226,37 @@ func (b *BotServer) eventHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (b *BotServer) reqHandler(req *discordgo.Interaction) (*discordgo.InteractionResponse, error) {
-	// Validate interaction and get command data
-	if req.Type != discordgo.InteractionApplicationCommand {
-		return nil, errors.New("unsupported interaction type")
+	switch req.Type {
+	case discordgo.InteractionApplicationCommand:
+		reqData := req.ApplicationCommandData()
+
+		// Checked here as well, since a server can let anyone see admin commands
+		if reqData.Name == command.RestoreCommand && !isAdmin(req) {
+			return &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("Only admins can use /%s", reqData.Name),
+				},
+			}, nil
+		}
+		return b.commandHandler(reqData)
+	case discordgo.InteractionMessageComponent:
+		return b.componentHandler(req.MessageComponentData())
+	}
+	return nil, errors.New("unsupported interaction type")
+}
+
+func (b *BotServer) commandHandler(reqData discordgo.ApplicationCommandInteractionData) (*discordgo.InteractionResponse, error) {
+	// Handle commands without a game
+	if reqData.Name == command.ExtendCommand {
+		minutes, err := command.GetMinutesChoice(reqData)
+		if err != nil {
+			return nil, err
+		}
+		return b.extendHandler(time.Duration(minutes) * time.Minute)
 	}
-	reqData := req.ApplicationCommandData()
+
+	// Get game the command is for
 	reqGame, err := command.GetGameChoice(reqData)
 	if err != nil {
 		return nil, err
@@ -210,18 +268,34 @@ func (b *BotServer) reqHandler(req *discordgo.Interaction) (*discordgo.Interacti
 		return b.startHandler(reqGame)
 	case command.StopCommand:
 		return b.stopHandler(reqGame)
+	case command.StatusCommand:
+		return b.statusHandler(reqGame)
+	case command.RestoreCommand:
+		reqDate, err := command.GetDateChoice(reqData)
+		if err != nil {
+			return nil, err
+		}
+		return b.restoreHandler(reqGame, reqDate)
 	}
 	return nil, fmt.Errorf("unsupported command: [%s]", reqData.Name)
 }
 
+func (b *BotServer) componentHandler(reqData discordgo.MessageComponentInteractionData) (*discordgo.InteractionResponse, error) {
+	switch reqData.CustomID {
+	case command.ExtendButton:
+		return b.extendHandler(ButtonExtension)
+	}
+	return nil, fmt.Errorf("unsupported component: [%s]", reqData.CustomID)
+}
+
 func (b *BotServer) startHandler(startGame string) (*discordgo.InteractionResponse, error) {
-	// Ensure a game is not already running
-	if runningGame, isRunning := b.gameClient.IsRunning(); isRunning {
-		b.logger.Info("recieved start request while game is running", zap.String("requestGame", startGame), zap.String("runningGame", runningGame))
+	// Ensure the game is not already running
+	if b.gameClient.IsRunning(startGame) {
+		b.logger.Info("recieved start request for game that is already running", zap.String("requestGame", startGame))
 		return &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("Cannot start %s server because %s is already running", startGame, runningGame),
+				Content: fmt.Sprintf("Cannot start %s server because it is already running", startGame),
 			},
 		}, nil
 	}
@@ -230,7 +304,17 @@ func (b *BotServer) startHandler(startGame string) (*discordgo.InteractionRespon
 	go func(game string) {
 		if err := b.gameClient.Run(game); err != nil {
 			b.logger.Error("failed to start game server", zap.Error(err), zap.String("game", game))
+
+			// Explain conflicts with other running games
+			var portErr gameserver.PortConflictError
+			var maxErr gameserver.MaxRunningError
 			msg := fmt.Sprintf("Could not start %s server", game)
+			switch {
+			case errors.As(err, &portErr):
+				msg = fmt.Sprintf("Cannot start %s server because %s is using port %d", game, portErr.RunningGame, portErr.Port)
+			case errors.As(err, &maxErr):
+				msg = fmt.Sprintf("Cannot start %s server because %d games are already running", game, maxErr.Max)
+			}
 			b.messageChannel(msg)
 		}
 	}(startGame)
@@ -245,9 +329,8 @@ func (b *BotServer) startHandler(startGame string) (*discordgo.InteractionRespon
 
 func (b *BotServer) stopHandler(stopGame string) (*discordgo.InteractionResponse, error) {
 	// Ensure requested game is currently running
-	runningGame, isRunning := b.gameClient.IsRunning()
-	if !isRunning || runningGame != stopGame {
-		b.logger.Info("recieved stop request for game that is not running", zap.String("requestGame", stopGame), zap.String("runningGame", runningGame))
+	if !b.gameClient.IsRunning(stopGame) {
+		b.logger.Info("recieved stop request for game that is not running", zap.String("requestGame", stopGame))
 		return &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -258,7 +341,7 @@ func (b *BotServer) stopHandler(stopGame string) (*discordgo.InteractionResponse
 
 	// Stop server
 	go func(game string) {
-		if err := b.gameClient.Stop(); err != nil {
+		if err := b.gameClient.Stop(game); err != nil {
 			b.logger.Error("failed to stop game server", zap.Error(err), zap.String("game", game))
 			msg := fmt.Sprintf("Could not stop %s server", game)
 			b.messageChannel(msg)
@@ -273,6 +356,131 @@ func (b *BotServer) stopHandler(stopGame string) (*discordgo.InteractionResponse
 	}, nil
 }
 
+func (b *BotServer) statusHandler(game string) (*discordgo.InteractionResponse, error) {
+	var content string
+	status, err := b.gameClient.Status(game)
+	switch {
+	case errors.Is(err, gameserver.ErrNoQuery):
+		content = fmt.Sprintf("Player information is not available for %s", game)
+	case err != nil && !b.gameClient.IsRunning(game):
+		content = fmt.Sprintf("%s server is not currently running", game)
+	case err != nil:
+		b.logger.Error("failed to get game server status", zap.Error(err), zap.String("game", game))
+		content = fmt.Sprintf("Could not get status of %s server", game)
+	default:
+		content = fmt.Sprintf("%s has %d/%d players online", game, status.Players, status.MaxPlayers)
+		if len(status.PlayerNames) > 0 {
+			content = fmt.Sprintf("%s: %s", content, strings.Join(status.PlayerNames, ", "))
+		}
+	}
+
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	}, nil
+}
+
+func (b *BotServer) extendHandler(extension time.Duration) (*discordgo.InteractionResponse, error) {
+	content := "Cannot delay the inactivity shutdown"
+	if b.monitor != nil {
+		remaining := b.monitor.Extend(extension)
+		content = fmt.Sprintf("Server will shut down if inactive for the next %s", remaining.Round(time.Minute))
+	}
+
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	}, nil
+}
+
+func (b *BotServer) restoreHandler(game string, date string) (*discordgo.InteractionResponse, error) {
+	var content string
+	backupDate, err := time.Parse(backup.DateFormat, date)
+	if err != nil {
+		content = fmt.Sprintf("Invalid date %s, expected YYYY-MM-DD", date)
+	} else if release, err := b.gameClient.Hold(game); err != nil {
+		// Game could not be kept from running until restored
+		switch {
+		case errors.As(err, &gameserver.HeldError{}):
+			content = fmt.Sprintf("%s save is already being restored", game)
+		case b.gameClient.IsRunning(game):
+			b.logger.Info("recieved restore request for game that is running", zap.String("requestGame", game))
+			content = fmt.Sprintf("Cannot restore %s save while it is running", game)
+		default:
+			b.logger.Error("failed to hold game for restore", zap.Error(err), zap.String("game", game))
+			content = fmt.Sprintf("Could not restore %s save from %s", game, date)
+		}
+	} else {
+		// Restore save, which may take longer than allowed for a response
+		go func() {
+			defer release()
+			if err := b.backup.Restore(game, backupDate); err != nil {
+				b.logger.Error("failed to restore save", zap.Error(err), zap.String("game", game), zap.String("date", date))
+				b.messageChannel(fmt.Sprintf("Could not restore %s save from %s", game, date))
+				return
+			}
+			b.messageChannel(fmt.Sprintf("Restored %s save from %s", game, date))
+		}()
+		content = fmt.Sprintf("Restoring %s save from %s", game, date)
+	}
+
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	}, nil
+}
+
+// ShutdownPending warns the channel of an inactivity shutdown, with a button to delay it
+func (b *BotServer) ShutdownPending(remaining time.Duration) {
+	msg := &discordgo.MessageSend{
+		Content: fmt.Sprintf("Server shutting down in %s due to inactivity", remaining.Round(time.Second)),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Keep running",
+						Style:    discordgo.PrimaryButton,
+						CustomID: command.ExtendButton,
+					},
+				},
+			},
+		},
+	}
+	if _, err := b.discordSession.ChannelMessageSendComplex(b.channelId, msg); err != nil {
+		b.logger.Error("could not send channel message", zap.Error(err), zap.String("channelMsg", msg.Content))
+	}
+}
+
+// Announce posts a message to the channel
+func (b *BotServer) Announce(msg string) {
+	b.messageChannel(msg)
+}
+
+func (b *BotServer) relayEvents(events <-chan gameserver.Event) {
+	for e := range events {
+		var readyErr gameserver.ReadyTimeoutError
+		var restartErr gameserver.RestartError
+		switch {
+		case errors.As(e.Err, &readyErr):
+			b.messageChannel(fmt.Sprintf("%s failed to become ready after %s", e.Game, readyErr.Timeout))
+		case errors.As(e.Err, &restartErr):
+			b.messageChannel(fmt.Sprintf("Could not restart %s server", e.Game))
+		case e.State == gameserver.StateRunning:
+			b.messageChannel(fmt.Sprintf("%s is ready to join", e.Game))
+		case e.State == gameserver.StateCrashed && e.Attempt > 0:
+			b.messageChannel(fmt.Sprintf("%s crashed (exit %d), restarting %d/%d", e.Game, e.ExitCode, e.Attempt, e.MaxAttempts))
+		case e.State == gameserver.StateCrashed:
+			b.messageChannel(fmt.Sprintf("%s crashed (exit %d)", e.Game, e.ExitCode))
+		}
+	}
+}
+
 func (b *BotServer) messageChannel(msg string) bool {
 	if _, err := b.discordSession.ChannelMessageSend(b.channelId, msg); err != nil {
 		b.logger.Error("could not send channel message", zap.Error(err), zap.String("channelMsg", msg))
@@ -281,6 +489,10 @@ func (b *BotServer) messageChannel(msg string) bool {
 	return true
 }
 
+func isAdmin(req *discordgo.Interaction) bool {
+	return req.Member != nil && req.Member.Permissions&discordgo.PermissionAdministrator != 0
+}
+
 func parseAndVerifyRequest(r *http.Request, publicKey crypto.PublicKey) (req *discordgo.Interaction, verified bool, err error) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -16,21 +16,25 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"game-server/internal/backup"
 	"game-server/internal/discord/command"
 	"game-server/internal/gameserver"
 	"game-server/internal/testing/mockserver"
 	"game-server/pkg/aws/sqs"
 	"game-server/pkg/discord"
+	"game-server/pkg/monitor"
+	"game-server/pkg/query"
 )
 
 func Test_New(t *testing.T) {
 	testCfg := mockserver.GetConfig(t)
 
-	s := New(testCfg, new(gameserver.MockClient))
+	s := New(testCfg, new(gameserver.MockClient), new(backup.MockClient))
 
 	require.NotNil(t, s)
 	assert.NotNil(t, s.sqsClient)
 	assert.NotNil(t, s.gameClient)
+	assert.NotNil(t, s.backup)
 	assert.NotNil(t, s.srv)
 }
 
@@ -129,7 +133,7 @@ func Test_BotServer_CheckMessageQueue(t *testing.T) {
 
 	// Setup mock game server client
 	mockGameClient := new(gameserver.MockClient)
-	mockGameClient.On(gameserver.IsRunningMethod).Return("", false)
+	mockGameClient.On(gameserver.IsRunningMethod, goodReqGame).Return(false)
 	mockGameClient.On(gameserver.RunMethod, goodReqGame).Return(nil)
 
 	mockErr := errors.New("mock error")
@@ -217,15 +221,14 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 	chanId := "channelId"
 	mockErr := errors.New("mock error")
 	tests := []struct {
-		name        string
-		req         *discordgo.Interaction
-		expContent  string
-		expErr      string
-		expChanMsg  string
-		isRunning   bool
-		runningGame string
-		runErr      error
-		stopErr     error
+		name       string
+		req        *discordgo.Interaction
+		expContent string
+		expErr     string
+		expChanMsg string
+		isRunning  bool
+		runErr     error
+		stopErr    error
 	}{
 		{
 			name: "Happy path - Start command",
@@ -260,9 +263,8 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 					},
 				},
 			},
-			expContent:  "is already running",
-			isRunning:   true,
-			runningGame: "otherGame",
+			expContent: "is already running",
+			isRunning:  true,
 		},
 		{
 			name: "Happy path - Stop command",
@@ -279,28 +281,8 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 					},
 				},
 			},
-			expContent:  "server is shutting down",
-			isRunning:   true,
-			runningGame: gameName,
-		},
-		{
-			name: "Happy path - Stop command with different game running",
-			req: &discordgo.Interaction{
-				Type: discordgo.InteractionApplicationCommand,
-				Data: discordgo.ApplicationCommandInteractionData{
-					Name: command.StopCommand,
-					Options: []*discordgo.ApplicationCommandInteractionDataOption{
-						{
-							Name:  command.GameOption,
-							Type:  discordgo.ApplicationCommandOptionString,
-							Value: gameName,
-						},
-					},
-				},
-			},
-			expContent:  "it is not currently running",
-			isRunning:   true,
-			runningGame: "otherGame",
+			expContent: "server is shutting down",
+			isRunning:  true,
 		},
 		{
 			name: "Happy path - Stop command with no game running",
@@ -355,6 +337,42 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 			expChanMsg: fmt.Sprintf("Could not start %s server", gameName),
 			runErr:     mockErr,
 		},
+		{
+			name: "Sad path - Game server port conflict",
+			req: &discordgo.Interaction{
+				Type: discordgo.InteractionApplicationCommand,
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: command.StartCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{
+							Name:  command.GameOption,
+							Type:  discordgo.ApplicationCommandOptionString,
+							Value: gameName,
+						},
+					},
+				},
+			},
+			expChanMsg: fmt.Sprintf("Cannot start %s server because otherGame is using port 27015", gameName),
+			runErr:     gameserver.PortConflictError{Game: gameName, RunningGame: "otherGame", Port: 27015},
+		},
+		{
+			name: "Sad path - Game server max running",
+			req: &discordgo.Interaction{
+				Type: discordgo.InteractionApplicationCommand,
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: command.StartCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{
+							Name:  command.GameOption,
+							Type:  discordgo.ApplicationCommandOptionString,
+							Value: gameName,
+						},
+					},
+				},
+			},
+			expChanMsg: fmt.Sprintf("Cannot start %s server because 2 games are already running", gameName),
+			runErr:     gameserver.MaxRunningError{Max: 2},
+		},
 		{
 			name: "Sad path - Game server stop error",
 			req: &discordgo.Interaction{
@@ -370,10 +388,9 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 					},
 				},
 			},
-			expChanMsg:  fmt.Sprintf("Could not stop %s server", gameName),
-			isRunning:   true,
-			runningGame: gameName,
-			stopErr:     mockErr,
+			expChanMsg: fmt.Sprintf("Could not stop %s server", gameName),
+			isRunning:  true,
+			stopErr:    mockErr,
 		},
 		{
 			name: "Sad path - Unknown command",
@@ -397,9 +414,9 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock game server client
 			mockGameClient := new(gameserver.MockClient)
-			mockGameClient.On(gameserver.IsRunningMethod).Return(tt.runningGame, tt.isRunning)
+			mockGameClient.On(gameserver.IsRunningMethod, gameName).Return(tt.isRunning)
 			mockGameClient.On(gameserver.RunMethod, gameName).Return(tt.runErr)
-			mockGameClient.On(gameserver.StopMethod).Return(tt.stopErr)
+			mockGameClient.On(gameserver.StopMethod, gameName).Return(tt.stopErr)
 
 			// Setup mock discord session
 			msgCall := make(chan string)
@@ -440,3 +457,414 @@ func Test_BotServer_RequestHandler(t *testing.T) {
 		})
 	}
 }
+
+func Test_BotServer_RelayEvents(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+
+	gameName := "gameName"
+	chanId := "channelId"
+	tests := []struct {
+		name       string
+		event      gameserver.Event
+		expChanMsg string
+	}{
+		{
+			name:       "Happy path - Game server ready",
+			event:      gameserver.Event{Game: gameName, State: gameserver.StateRunning},
+			expChanMsg: fmt.Sprintf("%s is ready to join", gameName),
+		},
+		{
+			name: "Happy path - Game server ready timeout",
+			event: gameserver.Event{
+				Game:  gameName,
+				State: gameserver.StateStarting,
+				Err:   gameserver.ReadyTimeoutError{Game: gameName, Timeout: time.Minute},
+			},
+			expChanMsg: fmt.Sprintf("%s failed to become ready after 1m0s", gameName),
+		},
+		{
+			name:       "Happy path - Game server crashed and restarting",
+			event:      gameserver.Event{Game: gameName, State: gameserver.StateCrashed, ExitCode: 137, Attempt: 2, MaxAttempts: 3},
+			expChanMsg: fmt.Sprintf("%s crashed (exit 137), restarting 2/3", gameName),
+		},
+		{
+			name:       "Happy path - Game server crashed without restart",
+			event:      gameserver.Event{Game: gameName, State: gameserver.StateCrashed, ExitCode: 1},
+			expChanMsg: fmt.Sprintf("%s crashed (exit 1)", gameName),
+		},
+		{
+			name: "Happy path - Game server restart failed",
+			event: gameserver.Event{
+				Game:  gameName,
+				State: gameserver.StateStopped,
+				Err:   gameserver.RestartError{Game: gameName, Err: errors.New("mock error")},
+			},
+			expChanMsg: fmt.Sprintf("Could not restart %s server", gameName),
+		},
+		{
+			name:  "Happy path - No message for game server starting",
+			event: gameserver.Event{Game: gameName, State: gameserver.StateStarting},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock discord session
+			mockSession := new(discord.MockDiscordSession)
+			mockSession.On(discord.SessionChannelMessageSendMethod, chanId, mock.Anything).Return(nil, nil)
+
+			b := &BotServer{
+				logger:         testCfg.Logger,
+				channelId:      chanId,
+				discordSession: mockSession,
+			}
+
+			events := make(chan gameserver.Event, 1)
+			events <- tt.event
+			close(events)
+			b.relayEvents(events)
+
+			if tt.expChanMsg != "" {
+				mockSession.AssertCalled(t, discord.SessionChannelMessageSendMethod, chanId, tt.expChanMsg)
+			} else {
+				mockSession.AssertNotCalled(t, discord.SessionChannelMessageSendMethod, chanId, mock.Anything)
+			}
+		})
+	}
+}
+
+func Test_BotServer_StatusHandler(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+
+	gameName := "gameName"
+	mockErr := errors.New("mock error")
+	tests := []struct {
+		name       string
+		expContent string
+		status     *query.Status
+		statusErr  error
+		isRunning  bool
+	}{
+		{
+			name:       "Happy path - Players online",
+			expContent: fmt.Sprintf("%s has 2/10 players online: alice, bob", gameName),
+			status:     &query.Status{Players: 2, MaxPlayers: 10, PlayerNames: []string{"alice", "bob"}},
+			isRunning:  true,
+		},
+		{
+			name:       "Happy path - No players online",
+			expContent: fmt.Sprintf("%s has 0/10 players online", gameName),
+			status:     &query.Status{MaxPlayers: 10},
+			isRunning:  true,
+		},
+		{
+			name:       "Happy path - No query configured",
+			expContent: fmt.Sprintf("Player information is not available for %s", gameName),
+			statusErr:  gameserver.ErrNoQuery,
+		},
+		{
+			name:       "Sad path - Game not running",
+			expContent: fmt.Sprintf("%s server is not currently running", gameName),
+			statusErr:  mockErr,
+		},
+		{
+			name:       "Sad path - Query error",
+			expContent: fmt.Sprintf("Could not get status of %s server", gameName),
+			statusErr:  mockErr,
+			isRunning:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock game server client
+			mockGameClient := new(gameserver.MockClient)
+			mockGameClient.On(gameserver.StatusMethod, gameName).Return(tt.status, tt.statusErr)
+			mockGameClient.On(gameserver.IsRunningMethod, gameName).Return(tt.isRunning)
+
+			b := &BotServer{
+				logger:     testCfg.Logger,
+				gameClient: mockGameClient,
+			}
+
+			resp, err := b.reqHandler(&discordgo.Interaction{
+				Type: discordgo.InteractionApplicationCommand,
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: command.StatusCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{
+							Name:  command.GameOption,
+							Type:  discordgo.ApplicationCommandOptionString,
+							Value: gameName,
+						},
+					},
+				},
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expContent, resp.Data.Content)
+		})
+	}
+}
+
+func Test_BotServer_ExtendHandler(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+
+	extendCmd := &discordgo.Interaction{
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: command.ExtendCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{
+					Name:  command.MinutesOption,
+					Type:  discordgo.ApplicationCommandOptionInteger,
+					Value: float64(20),
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name         string
+		req          *discordgo.Interaction
+		noMonitor    bool
+		expExtension time.Duration
+		expContent   string
+		expErr       string
+	}{
+		{
+			name:         "Happy path - Extend command",
+			req:          extendCmd,
+			expExtension: 20 * time.Minute,
+			expContent:   "Server will shut down if inactive for the next 45m0s",
+		},
+		{
+			name: "Happy path - Extend button",
+			req: &discordgo.Interaction{
+				Type: discordgo.InteractionMessageComponent,
+				Data: discordgo.MessageComponentInteractionData{
+					CustomID: command.ExtendButton,
+				},
+			},
+			expExtension: ButtonExtension,
+			expContent:   "Server will shut down if inactive for the next 45m0s",
+		},
+		{
+			name:       "Sad path - No monitor",
+			req:        extendCmd,
+			noMonitor:  true,
+			expContent: "Cannot delay the inactivity shutdown",
+		},
+		{
+			name: "Sad path - Unknown button",
+			req: &discordgo.Interaction{
+				Type: discordgo.InteractionMessageComponent,
+				Data: discordgo.MessageComponentInteractionData{
+					CustomID: "badButton",
+				},
+			},
+			expErr: "unsupported component",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMonitor := new(monitor.MockClient)
+			mockMonitor.On(monitor.ExtendMethod, tt.expExtension).Return(45 * time.Minute)
+
+			b := &BotServer{
+				logger: testCfg.Logger,
+			}
+			if !tt.noMonitor {
+				b.SetMonitor(mockMonitor)
+			}
+
+			resp, err := b.reqHandler(tt.req)
+
+			if tt.expErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expContent, resp.Data.Content)
+			if !tt.noMonitor {
+				mockMonitor.AssertCalled(t, monitor.ExtendMethod, tt.expExtension)
+			}
+		})
+	}
+}
+
+func Test_BotServer_RestoreHandler(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+
+	chanId := "channelId"
+	gameName := "gameName"
+	date := "2023-04-05"
+	mockErr := errors.New("mock error")
+
+	tests := []struct {
+		name       string
+		date       string
+		permission int64
+		holdErr    error
+		isRunning  bool
+		restoreErr error
+		expContent string
+		expChanMsg string
+	}{
+		{
+			name:       "Happy path",
+			date:       date,
+			permission: discordgo.PermissionAdministrator,
+			expContent: fmt.Sprintf("Restoring %s save from %s", gameName, date),
+			expChanMsg: fmt.Sprintf("Restored %s save from %s", gameName, date),
+		},
+		{
+			name:       "Sad path - Not admin",
+			date:       date,
+			permission: discordgo.PermissionSendMessages,
+			expContent: "Only admins can use /restore",
+		},
+		{
+			name:       "Sad path - Game running",
+			date:       date,
+			permission: discordgo.PermissionAdministrator,
+			holdErr:    mockErr,
+			isRunning:  true,
+			expContent: fmt.Sprintf("Cannot restore %s save while it is running", gameName),
+		},
+		{
+			name:       "Sad path - Already restoring",
+			date:       date,
+			permission: discordgo.PermissionAdministrator,
+			holdErr:    gameserver.HeldError{Game: gameName},
+			expContent: fmt.Sprintf("%s save is already being restored", gameName),
+		},
+		{
+			name:       "Sad path - Invalid date",
+			date:       "05/04/2023",
+			permission: discordgo.PermissionAdministrator,
+			expContent: "Invalid date 05/04/2023, expected YYYY-MM-DD",
+		},
+		{
+			name:       "Sad path - Restore error",
+			date:       date,
+			permission: discordgo.PermissionAdministrator,
+			restoreErr: mockErr,
+			expContent: fmt.Sprintf("Restoring %s save from %s", gameName, date),
+			expChanMsg: fmt.Sprintf("Could not restore %s save from %s", gameName, date),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			released := make(chan struct{})
+			var release func()
+			if tt.holdErr == nil {
+				release = func() { close(released) }
+			}
+			mockGameClient := new(gameserver.MockClient)
+			mockGameClient.On(gameserver.HoldMethod, gameName).Return(release, tt.holdErr)
+			mockGameClient.On(gameserver.IsRunningMethod, gameName).Return(tt.isRunning)
+
+			mockBackup := new(backup.MockClient)
+			mockBackup.On(backup.RestoreMethod, gameName, time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)).Return(tt.restoreErr)
+
+			// Setup mock discord session
+			msgCall := make(chan string)
+			mockSession := new(discord.MockDiscordSession)
+			chanMsgCall := mockSession.On(discord.SessionChannelMessageSendMethod, chanId, tt.expChanMsg)
+			chanMsgCall.Run(func(args mock.Arguments) {
+				msgCall <- args.String(1)
+			})
+			chanMsgCall.Return(nil, nil)
+
+			b := &BotServer{
+				logger:         testCfg.Logger,
+				channelId:      chanId,
+				gameClient:     mockGameClient,
+				backup:         mockBackup,
+				discordSession: mockSession,
+			}
+
+			resp, err := b.reqHandler(&discordgo.Interaction{
+				Type:   discordgo.InteractionApplicationCommand,
+				Member: &discordgo.Member{Permissions: tt.permission},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: command.RestoreCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{
+							Name:  command.GameOption,
+							Type:  discordgo.ApplicationCommandOptionString,
+							Value: gameName,
+						},
+						{
+							Name:  command.DateOption,
+							Type:  discordgo.ApplicationCommandOptionString,
+							Value: tt.date,
+						},
+					},
+				},
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expContent, resp.Data.Content)
+
+			// Check for expected channel message
+			if tt.expChanMsg != "" {
+				select {
+				case msg := <-msgCall:
+					assert.Equal(t, tt.expChanMsg, msg)
+				case <-time.After(time.Second):
+					assert.Fail(t, "Expected channel message was not recieved")
+				}
+
+				// Game is released once restored
+				select {
+				case <-released:
+				case <-time.After(time.Second):
+					assert.Fail(t, "Game was not released after restore")
+				}
+			} else {
+				mockBackup.AssertNotCalled(t, backup.RestoreMethod, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func Test_BotServer_ShutdownPending(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+	chanId := "channelId"
+
+	mockSession := new(discord.MockDiscordSession)
+	mockSession.On(discord.SessionChannelMessageSendComplexMethod, chanId, mock.Anything).Return(nil, nil)
+
+	b := &BotServer{
+		logger:         testCfg.Logger,
+		channelId:      chanId,
+		discordSession: mockSession,
+	}
+	b.ShutdownPending(5 * time.Minute)
+
+	// Check message has a button to extend
+	msg := mockSession.Calls[0].Arguments.Get(1).(*discordgo.MessageSend)
+	assert.Equal(t, "Server shutting down in 5m0s due to inactivity", msg.Content)
+	require.Len(t, msg.Components, 1)
+	row := msg.Components[0].(discordgo.ActionsRow)
+	require.Len(t, row.Components, 1)
+	assert.Equal(t, command.ExtendButton, row.Components[0].(discordgo.Button).CustomID)
+}
+
+func Test_BotServer_Announce(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+	chanId := "channelId"
+	msg := "Server shutting down in 2m0s, the host is being reclaimed"
+
+	mockSession := new(discord.MockDiscordSession)
+	mockSession.On(discord.SessionChannelMessageSendMethod, chanId, msg).Return(nil, nil)
+
+	b := &BotServer{
+		logger:         testCfg.Logger,
+		channelId:      chanId,
+		discordSession: mockSession,
+	}
+	b.Announce(msg)
+
+	mockSession.AssertCalled(t, discord.SessionChannelMessageSendMethod, chanId, msg)
+}
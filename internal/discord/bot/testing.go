@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	SetMonitorMethod      = "SetMonitor"
+	ConnectMethod         = "Connect"
+	RunMethod             = "Run"
+	StopMethod            = "Stop"
+	ShutdownPendingMethod = "ShutdownPending"
+	AnnounceMethod        = "Announce"
+)
+
+// Ensure MockServer implements ServerIFace
+var _ ServerIFace = (*MockServer)(nil)
+
+type MockServer struct {
+	mock.Mock
+}
+
+func (m *MockServer) SetMonitor(mon Extender) {
+	m.Called(mon)
+}
+
+func (m *MockServer) Connect() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockServer) Run() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockServer) Stop() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockServer) ShutdownPending(remaining time.Duration) {
+	m.Called(remaining)
+}
+
+func (m *MockServer) Announce(msg string) {
+	m.Called(msg)
+}
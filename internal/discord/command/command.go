@@ -7,9 +7,20 @@ import (
 )
 
 const (
-	StartCommand = "start"
-	StopCommand  = "stop"
-	GameOption   = "game"
+	StartCommand   = "start"
+	StopCommand    = "stop"
+	StatusCommand  = "status"
+	ExtendCommand  = "extend"
+	RestoreCommand = "restore"
+	GameOption     = "game"
+	MinutesOption  = "minutes"
+	DateOption     = "date"
+
+	// Message components
+	ExtendButton = "extend"
+
+	// Path the bot on the instance receives interactions forwarded by the lambda
+	BotEndpoint = "/discord"
 )
 
 var commands = []*discordgo.ApplicationCommand{
@@ -25,8 +36,30 @@ var commands = []*discordgo.ApplicationCommand{
 		Description: "Stop a game server",
 		Options:     []*discordgo.ApplicationCommandOption{gameOption},
 	},
+	{
+		Name:        StatusCommand,
+		Type:        1,
+		Description: "Get the players on a game server",
+		Options:     []*discordgo.ApplicationCommandOption{gameOption},
+	},
+	{
+		Name:        ExtendCommand,
+		Type:        1,
+		Description: "Delay the inactivity shutdown",
+		Options:     []*discordgo.ApplicationCommandOption{minutesOption},
+	},
+	{
+		Name:                     RestoreCommand,
+		Type:                     1,
+		Description:              "Restore a game's save from a backup",
+		Options:                  []*discordgo.ApplicationCommandOption{gameOption, dateOption},
+		DefaultMemberPermissions: &adminPermission,
+	},
 }
 
+// Only shown to admins by default, though a server may change who can use it
+var adminPermission = int64(discordgo.PermissionAdministrator)
+
 var gameOption = &discordgo.ApplicationCommandOption{
 	Name:        GameOption,
 	Type:        3,
@@ -34,6 +67,23 @@ var gameOption = &discordgo.ApplicationCommandOption{
 	Required:    true,
 }
 
+var minMinutes = float64(1)
+
+var minutesOption = &discordgo.ApplicationCommandOption{
+	Name:        MinutesOption,
+	Type:        4,
+	Description: "Specify how many minutes",
+	Required:    true,
+	MinValue:    &minMinutes,
+}
+
+var dateOption = &discordgo.ApplicationCommandOption{
+	Name:        DateOption,
+	Type:        3,
+	Description: "Specify the date, as YYYY-MM-DD",
+	Required:    true,
+}
+
 func GetGameChoice(cmd discordgo.ApplicationCommandInteractionData) (string, error) {
 	for _, c := range cmd.Options {
 		if c.Name == GameOption && c.Type == discordgo.ApplicationCommandOptionString {
@@ -45,3 +95,30 @@ func GetGameChoice(cmd discordgo.ApplicationCommandInteractionData) (string, err
 	}
 	return "", errors.New("command missing game choice")
 }
+
+func GetMinutesChoice(cmd discordgo.ApplicationCommandInteractionData) (int, error) {
+	for _, c := range cmd.Options {
+		if c.Name == MinutesOption && c.Type == discordgo.ApplicationCommandOptionInteger {
+			// Numbers are decoded from JSON as floats
+			switch c.Value.(type) {
+			case float64:
+				if minutes := int(c.Value.(float64)); minutes > 0 {
+					return minutes, nil
+				}
+			}
+		}
+	}
+	return 0, errors.New("command missing minutes choice")
+}
+
+func GetDateChoice(cmd discordgo.ApplicationCommandInteractionData) (string, error) {
+	for _, c := range cmd.Options {
+		if c.Name == DateOption && c.Type == discordgo.ApplicationCommandOptionString {
+			switch c.Value.(type) {
+			case string:
+				return c.Value.(string), nil
+			}
+		}
+	}
+	return "", errors.New("command missing date choice")
+}
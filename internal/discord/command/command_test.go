@@ -98,3 +98,126 @@ func Test_GetGameChoice(t *testing.T) {
 		})
 	}
 }
+
+func Test_GetMinutesChoice(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmdData    discordgo.ApplicationCommandInteractionData
+		expMinutes int
+		expErr     bool
+	}{
+		{
+			name: "Happy path",
+			cmdData: discordgo.ApplicationCommandInteractionData{
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name:  MinutesOption,
+						Type:  discordgo.ApplicationCommandOptionInteger,
+						Value: float64(30),
+					},
+				},
+			},
+			expMinutes: 30,
+		},
+		{
+			name:    "Sad path - Nil options",
+			cmdData: discordgo.ApplicationCommandInteractionData{},
+			expErr:  true,
+		},
+		{
+			name: "Sad path - Non-integer option type",
+			cmdData: discordgo.ApplicationCommandInteractionData{
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name:  MinutesOption,
+						Type:  discordgo.ApplicationCommandOptionString,
+						Value: "30",
+					},
+				},
+			},
+			expErr: true,
+		},
+		{
+			name: "Sad path - Not positive",
+			cmdData: discordgo.ApplicationCommandInteractionData{
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name:  MinutesOption,
+						Type:  discordgo.ApplicationCommandOptionInteger,
+						Value: float64(0),
+					},
+				},
+			},
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMinutes, err := GetMinutesChoice(tt.cmdData)
+
+			if !tt.expErr {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expMinutes, gotMinutes)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_GetDateChoice(t *testing.T) {
+	date := "2023-04-05"
+	tests := []struct {
+		name    string
+		cmdData discordgo.ApplicationCommandInteractionData
+		expErr  bool
+	}{
+		{
+			name: "Happy path",
+			cmdData: discordgo.ApplicationCommandInteractionData{
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name:  GameOption,
+						Type:  discordgo.ApplicationCommandOptionString,
+						Value: "gameName",
+					},
+					{
+						Name:  DateOption,
+						Type:  discordgo.ApplicationCommandOptionString,
+						Value: date,
+					},
+				},
+			},
+		},
+		{
+			name:    "Sad path - Nil options",
+			cmdData: discordgo.ApplicationCommandInteractionData{},
+			expErr:  true,
+		},
+		{
+			name: "Sad path - Non-string value",
+			cmdData: discordgo.ApplicationCommandInteractionData{
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name:  DateOption,
+						Type:  discordgo.ApplicationCommandOptionString,
+						Value: 20230405,
+					},
+				},
+			},
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDate, err := GetDateChoice(tt.cmdData)
+
+			if !tt.expErr {
+				require.NoError(t, err)
+				assert.Equal(t, date, gotDate)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
@@ -92,9 +92,12 @@ func Test_Register(t *testing.T) {
 			createCall.Return(nil, tt.createErr)
 			c.discordSession = mockSession
 
-			// Ensure all games are set as options
+			// Ensure all games are set as options on game commands
 			createCall.Run(func(args mock.Arguments) {
 				cmd := args.Get(2).(*discordgo.ApplicationCommand)
+				if cmd.Name == ExtendCommand {
+					return
+				}
 				foundGameOption := false
 				missing := make([]string, 0, len(gameNames))
 				for _, op := range cmd.Options {
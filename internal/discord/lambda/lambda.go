@@ -18,7 +18,7 @@ import (
 	"go.uber.org/zap"
 
 	"game-server/internal/config"
-	"game-server/internal/discord/bot"
+	"game-server/internal/discord/command"
 	"game-server/pkg/aws/instance"
 	"game-server/pkg/aws/sqs"
 	"game-server/pkg/discord"
@@ -202,7 +202,7 @@ func (h *Handler) forwardToInstance(reqBody []byte, headers map[string]string) e
 		h.logger.Error("failed to get instance address", zap.Error(err))
 		return internalErrorResponse
 	}
-	endpoint := fmt.Sprintf("http://%s%s", instanceAddress, bot.BotEndpoint)
+	endpoint := fmt.Sprintf("http://%s%s", instanceAddress, command.BotEndpoint)
 
 	// Build HTTP request
 	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(reqBody))
@@ -0,0 +1,53 @@
+// Package gamelock keeps a game from being run and restored at the same time, even by different processes,
+// by locking a file for it in its working directory
+package gamelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	filePrefix = ".game-server-"
+	fileSuffix = ".lock"
+)
+
+var ErrLocked = errors.New("game is locked by another run or restore")
+
+// FileName of the lock for a game in its working directory, games sharing a directory are locked separately
+func FileName(game string) string {
+	return filePrefix + game + fileSuffix
+}
+
+// IsLockFile checks if the name is that of a lock for any game, which is never a save file
+func IsLockFile(name string) bool {
+	return strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix)
+}
+
+// Lock is held on a game until released
+type Lock struct {
+	f *os.File
+}
+
+// Acquire locks the game in its working directory, failing with ErrLocked if it is already locked
+func Acquire(workingDir, game string) (*Lock, error) {
+	if workingDir == "" {
+		workingDir = "."
+	}
+	if err := os.MkdirAll(workingDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := lockFile(filepath.Join(workingDir, FileName(game)))
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release unlocks the game, the lock file is left in place for the next lock
+func (l *Lock) Release() error {
+	return unlockFile(l.f)
+}
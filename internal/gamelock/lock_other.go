@@ -0,0 +1,23 @@
+//go:build !linux
+
+package gamelock
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is only an advisory lock on Linux, elsewhere the file is created exclusively and must be removed
+// by hand should the process exit without releasing it
+func lockFile(filePath string) (*os.File, error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, ErrLocked
+	}
+	return f, err
+}
+
+func unlockFile(f *os.File) error {
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,35 @@
+package gamelock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Acquire(t *testing.T) {
+	workingDir := t.TempDir()
+
+	// Only one lock is held on a game at a time
+	lock, err := Acquire(workingDir, "game")
+	require.NoError(t, err)
+	_, err = Acquire(workingDir, "game")
+	assert.ErrorIs(t, err, ErrLocked)
+
+	// Released lock can be taken again
+	require.NoError(t, lock.Release())
+	lock, err = Acquire(workingDir, "game")
+	require.NoError(t, err)
+
+	// Other games are locked separately, even in the same working directory
+	other, err := Acquire(workingDir, "other")
+	require.NoError(t, err)
+	require.NoError(t, other.Release())
+	require.NoError(t, lock.Release())
+}
+
+func Test_IsLockFile(t *testing.T) {
+	assert.True(t, IsLockFile(FileName("game")))
+	assert.False(t, IsLockFile("game.lock"))
+	assert.False(t, IsLockFile("savefile.txt"))
+}
@@ -0,0 +1,87 @@
+package gameserver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"game-server/internal/config"
+	"game-server/pkg/rcon"
+)
+
+// commander sends console commands to a game server
+type commander interface {
+	send(command string) error
+	close()
+}
+
+func newCommander(gameCfg *config.GameConfig, in io.Writer) commander {
+	if gameCfg.CommandTransport == config.TransportRCON {
+		return &rconCommander{
+			// Game servers are always local to the service
+			address:  net.JoinHostPort("127.0.0.1", strconv.Itoa(int(gameCfg.RCON.Port))),
+			password: gameCfg.RCON.Password,
+		}
+	}
+	return &stdinCommander{in: in}
+}
+
+// stdinCommander writes commands to the console input of the process
+type stdinCommander struct {
+	in io.Writer
+}
+
+func (c *stdinCommander) send(command string) error {
+	_, err := io.WriteString(c.in, command+"\n")
+	return err
+}
+
+// Input is closed along with the process
+func (c *stdinCommander) close() {}
+
+// rconCommander sends commands over RCON, connecting on first use since the
+// server only listens once it has started
+type rconCommander struct {
+	address  string
+	password string
+
+	mu     sync.Mutex
+	client *rcon.Client
+}
+
+func (c *rconCommander) send(command string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Reconnect once if the existing connection has gone stale, but never resend a command the
+	// server may have received, since it could run twice
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.client == nil {
+			if c.client, err = rcon.Dial(c.address, c.password); err != nil {
+				return err
+			}
+		}
+		if _, err = c.client.Execute(command); err == nil {
+			return nil
+		}
+		c.client.Close()
+		c.client = nil
+		if !errors.As(err, &rcon.SendError{}) {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *rconCommander) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
@@ -0,0 +1,198 @@
+package gameserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	EnvLogDir = "GAME_LOG_DIR"
+
+	defaultLogDir = "logs"
+
+	// Log file rotation
+	logMaxSizeMB  = 10
+	logMaxBackups = 5
+
+	stdoutStream = "stdout"
+	stderrStream = "stderr"
+
+	// Lines longer than this are split into multiple entries
+	maxLineLength = 64 * 1024
+)
+
+// console drains the output streams of a game server for the lifetime of its process,
+// writing each line to a rotating log file and to the logger
+type console struct {
+	logger  *zap.Logger
+	file    io.WriteCloser
+	streams []*stream
+
+	mu       sync.Mutex
+	fileErr  bool
+	watchers map[*watcher]struct{}
+
+	done chan struct{}
+}
+
+type watcher struct {
+	pattern *regexp.Regexp
+	match   chan string
+}
+
+type stream struct {
+	name string
+	r    *os.File
+	w    *os.File
+}
+
+func newConsole(logger *zap.Logger, game string) (*console, error) {
+	logDir, ok := os.LookupEnv(EnvLogDir)
+	if !ok || logDir == "" {
+		logDir = defaultLogDir
+	}
+
+	c := &console{
+		logger: logger,
+		file: &lumberjack.Logger{
+			Filename:   filepath.Join(logDir, fmt.Sprintf("%s.log", game)),
+			MaxSize:    logMaxSizeMB,
+			MaxBackups: logMaxBackups,
+		},
+		watchers: make(map[*watcher]struct{}),
+		done:     make(chan struct{}),
+	}
+
+	for _, name := range []string{stdoutStream, stderrStream} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		c.streams = append(c.streams, &stream{name: name, r: r, w: w})
+	}
+
+	return c, nil
+}
+
+// stdout returns the writer to be used as the process stdout
+func (c *console) stdout() *os.File {
+	return c.streams[0].w
+}
+
+// stderr returns the writer to be used as the process stderr
+func (c *console) stderr() *os.File {
+	return c.streams[1].w
+}
+
+// start begins draining each stream, must be called after the process has started
+func (c *console) start() {
+	var wg sync.WaitGroup
+	for _, s := range c.streams {
+		// Process holds its own copy of the write end, closing ours allows EOF on exit
+		s.w.Close()
+
+		wg.Add(1)
+		go func(s *stream) {
+			defer wg.Done()
+			defer s.r.Close()
+			c.pump(s)
+		}(s)
+	}
+
+	go func() {
+		wg.Wait()
+		c.file.Close()
+		close(c.done)
+	}()
+}
+
+// close releases all resources without draining, used when the process failed to start
+func (c *console) close() {
+	for _, s := range c.streams {
+		s.r.Close()
+		s.w.Close()
+	}
+	c.file.Close()
+}
+
+// watch returns a channel that receives the first line of output matching the pattern,
+// the returned function stops watching and should always be called
+func (c *console) watch(pattern *regexp.Regexp) (<-chan string, func()) {
+	w := &watcher{
+		pattern: pattern,
+		match:   make(chan string, 1),
+	}
+
+	c.mu.Lock()
+	c.watchers[w] = struct{}{}
+	c.mu.Unlock()
+
+	return w.match, func() {
+		c.mu.Lock()
+		delete(c.watchers, w)
+		c.mu.Unlock()
+	}
+}
+
+func (c *console) pump(s *stream) {
+	buf := bufio.NewReaderSize(s.r, maxLineLength)
+	for {
+		line, err := buf.ReadSlice('\n')
+		if len(line) > 0 {
+			c.writeLine(s.name, trimNewline(line))
+		}
+
+		// Emit overlong lines in chunks rather than stalling the stream
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		} else if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+				c.logger.Error("could not read game server output", zap.Error(err), zap.String("stream", s.name))
+			}
+			return
+		}
+	}
+}
+
+func (c *console) writeLine(streamName string, line string) {
+	c.logger.Info("console output", zap.String("stream", streamName), zap.String("line", line))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Notify any watchers that match the line
+	for w := range c.watchers {
+		if w.pattern.MatchString(line) {
+			w.match <- line
+			delete(c.watchers, w)
+		}
+	}
+
+	entry := fmt.Sprintf("%s [%s] %s\n", time.Now().Format(time.RFC3339), streamName, line)
+	if _, err := io.WriteString(c.file, entry); err != nil && !c.fileErr {
+		// Only report the first failure to avoid flooding the log
+		c.fileErr = true
+		c.logger.Error("could not write to console log file", zap.Error(err))
+	}
+}
+
+func trimNewline(line []byte) string {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	return string(line)
+}
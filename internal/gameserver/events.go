@@ -0,0 +1,64 @@
+package gameserver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const subscriberBufferSize = 16
+
+// Event reports a change in a game server's state, or a failure while in its current state
+type Event struct {
+	Game  string
+	From  State
+	State State
+	Err   error
+
+	// Set when crashed
+	ExitCode    int
+	Attempt     int // Restart attempt scheduled, zero if not restarting
+	MaxAttempts int
+}
+
+// ReadyTimeoutError is reported when a game server does not log its ready pattern in time,
+// it still becomes running if the pattern is logged later
+type ReadyTimeoutError struct {
+	Game    string
+	Timeout time.Duration
+}
+
+func (e ReadyTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not become ready after %s", e.Game, e.Timeout)
+}
+
+// publisher fans out events to all subscribers
+type publisher struct {
+	logger *zap.Logger
+	mu     sync.Mutex
+	subs   []chan Event
+}
+
+func (p *publisher) subscribe() <-chan Event {
+	sub := make(chan Event, subscriberBufferSize)
+	p.mu.Lock()
+	p.subs = append(p.subs, sub)
+	p.mu.Unlock()
+	return sub
+}
+
+func (p *publisher) publish(e Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Never block the game server lifecycle on a slow subscriber
+	for _, sub := range p.subs {
+		select {
+		case sub <- e:
+		default:
+			p.logger.Warn("dropped game server event for slow subscriber", zap.String("game", e.Game), zap.Stringer("state", e.State))
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package gameserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"net"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"game-server/internal/config"
+	"game-server/internal/gamelock"
+	"game-server/pkg/query"
 
 	"go.uber.org/zap"
 )
@@ -21,6 +30,8 @@ const (
 var (
 	ServerShutdownDelay   time.Duration = 30 * time.Second
 	ServerShutdownTimeout time.Duration = 10 * time.Second
+	ServerKillTimeout     time.Duration = 10 * time.Second // Wait for the process to exit once killed
+	ServerReadyTimeout    time.Duration = 5 * time.Minute  // Default when not set by game config
 )
 
 // Ensure Client implements ClientIFace
@@ -28,74 +39,408 @@ var _ ClientIFace = (*Client)(nil)
 
 type ClientIFace interface {
 	Run(game string) error
-	IsRunning() (gameName string, isRunning bool)
-	Stop() error
+	IsRunning(game string) bool
+	Running() []string
+	Hold(game string) (func(), error)
+	Stop(game string) error
+	Message(game string, message string) error
+	Command(ctx context.Context, game string, command string, confirm *regexp.Regexp, timeout time.Duration) error
+	Status(game string) (*query.Status, error)
+	Subscribe() <-chan Event
 }
 
+// ErrNoQuery is returned when getting the status of a game without a query protocol
+var ErrNoQuery = errors.New("game has no query protocol configured")
+
+// Client manages any number of concurrently running game servers, keyed by game name
 type Client struct {
-	cfg     *config.Config
-	running *server
+	cfg    *config.Config
+	logger *zap.Logger
+	events *publisher
+
+	mu    sync.Mutex
+	games map[string]*game
 }
 
 func New(cfg *config.Config) *Client {
+	logger := cfg.Logger.Named(loggerName)
 	return &Client{
-		cfg: cfg,
+		cfg:    cfg,
+		logger: logger,
+		events: &publisher{logger: logger},
+		games:  make(map[string]*game),
 	}
 }
 
 func (c *Client) Run(game string) error {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
+	if !ok {
+		return fmt.Errorf("no configuration for game: [%s]", game)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Check game can be started from its current state
+	g := c.getGame(gameCfg.Name)
+	if !canTransition(g.state, StateStarting) || g.restart != nil {
+		return TransitionError{Game: g.name, From: g.state, To: StateStarting}
+	} else if g.held {
+		return HeldError{Game: g.name}
+	}
+
+	// Ensure game can run alongside those already running
+	if err := c.checkCanRun(gameCfg); err != nil {
+		return err
+	}
+
+	// Lock the game in its working directory while active, so it is never restored from another process while running.
+	// Locked before getting the game server, which opens its pipes and logs.
+	lock, err := gamelock.Acquire(gameCfg.WorkingDir, g.name)
+	if errors.Is(err, gamelock.ErrLocked) {
+		return HeldError{Game: g.name}
+	} else if err != nil {
+		return err
+	}
+	g.lock = lock
+
 	// Get game server
 	s, err := newGameServer(c.cfg, game)
 	if err != nil {
+		g.unlock()
 		return err
 	}
+	if err := c.launch(g, s); err != nil {
+		g.unlock()
+		return err
+	}
+	return nil
+}
 
-	// Stop currently running game
-	if c.running != nil {
-		if err := c.Stop(); err != nil {
-			return fmt.Errorf("could not stop current game server: %w", err)
+func (c *Client) IsRunning(game string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.games[gameKey(game)]
+	return ok && g.isActive()
+}
+
+// Hold keeps a game that is not running from being run until released, such as while its save files are restored
+func (c *Client) Hold(game string) (func(), error) {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
+	if !ok {
+		return nil, fmt.Errorf("no configuration for game: [%s]", game)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.getGame(gameCfg.Name)
+	if g.isActive() {
+		return nil, fmt.Errorf("game is running: [%s]", g.name)
+	} else if g.held {
+		return nil, HeldError{Game: g.name}
+	}
+	g.held = true
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			g.held = false
+		})
+	}, nil
+}
+
+// State gets the current lifecycle state of a game
+func (c *Client) State(game string) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if g, ok := c.games[gameKey(game)]; ok {
+		return g.state
+	}
+	return StateStopped
+}
+
+// Running gets the names of all running games
+func (c *Client) Running() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var games []string
+	for _, g := range c.games {
+		if g.isActive() {
+			games = append(games, g.name)
 		}
 	}
+	sort.Strings(games)
+	return games
+}
 
-	if err := s.run.Start(); err != nil {
+func (c *Client) Stop(game string) error {
+	c.mu.Lock()
+	g, ok := c.games[gameKey(game)]
+	if !ok {
+		c.mu.Unlock()
+		return TransitionError{Game: game, From: StateStopped, To: StateStopping}
+	}
+
+	// A crashed game has no process to stop, only a possible pending restart to cancel
+	if g.state == StateCrashed {
+		if r := g.restart; r != nil {
+			r.timer.Stop()
+			g.restart = nil
+		}
+		err := c.transition(g, StateStopped, Event{})
+		c.mu.Unlock()
 		return err
 	}
-	c.running = s
-	return nil
+
+	// Claim the stop, any concurrent stop will fail this transition
+	if err := c.transition(g, StateStopping, Event{}); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	s := g.server
+	c.mu.Unlock()
+
+	// Attempt stop, the process has exited either way once this returns without error
+	err := s.stopServer()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g.server = nil
+	if transitionErr := c.transition(g, StateStopped, Event{Err: err}); transitionErr != nil {
+		return transitionErr
+	}
+	return err
 }
 
-func (c *Client) IsRunning() (gameName string, isRunning bool) {
-	if c.running != nil {
-		return c.running.name, true
+// Message sends a message to the players of a running game
+func (c *Client) Message(game string, message string) error {
+	s, err := c.getServer(game)
+	if err != nil {
+		return err
 	}
-	return "", false
+	return s.message(message)
 }
 
-func (c *Client) Stop() error {
-	// Check that a server is running
-	if c.running == nil {
-		return fmt.Errorf("no running server to stop")
+// Command sends a console command to a running game, then waits for a line of output
+// matching the confirm pattern if given, until the timeout or the context is done
+func (c *Client) Command(ctx context.Context, game string, command string, confirm *regexp.Regexp, timeout time.Duration) error {
+	s, err := c.getServer(game)
+	if err != nil {
+		return err
+	} else if confirm == nil {
+		return s.cmd.send(command)
 	}
 
-	// Attempt stop
-	if err := c.running.stopServer(); err != nil {
+	// Watch before sending, so a quick response is not missed
+	match, cancel := s.console.watch(confirm)
+	defer cancel()
+	if err := s.cmd.send(command); err != nil {
 		return err
 	}
 
-	// Clear running status after successful stop
-	c.running = nil
+	select {
+	case <-match:
+		return nil
+	case <-time.After(timeout):
+		return ConfirmTimeoutError{Game: s.name, Command: command, Timeout: timeout}
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.exited:
+		return fmt.Errorf("game exited before confirming command: [%s]", command)
+	}
+}
+
+// getServer gets the process of a running game
+func (c *Client) getServer(game string) (*server, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.games[gameKey(game)]
+	if !ok || g.server == nil {
+		return nil, fmt.Errorf("game is not running: [%s]", game)
+	}
+	return g.server, nil
+}
+
+// Status queries a running game server for its players
+func (c *Client) Status(game string) (*query.Status, error) {
+	gameCfg, ok := c.cfg.GetGameConfig(game)
+	if !ok {
+		return nil, fmt.Errorf("no configuration for game: [%s]", game)
+	} else if gameCfg.Query.Protocol == "" {
+		return nil, ErrNoQuery
+	} else if !c.IsRunning(game) {
+		return nil, fmt.Errorf("game is not running: [%s]", gameCfg.Name)
+	}
+
+	// Game servers are always local to the service
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(gameCfg.Query.Port)))
+	q, err := query.New(gameCfg.Query.Protocol, address)
+	if err != nil {
+		return nil, err
+	}
+	return q.Query()
+}
+
+// PortConflictError is returned when a game needs a port already used by a running game
+type PortConflictError struct {
+	Game        string
+	RunningGame string
+	Port        int32
+}
+
+func (e PortConflictError) Error() string {
+	return fmt.Sprintf("%s cannot use port %d while %s is running", e.Game, e.Port, e.RunningGame)
+}
+
+// HeldError is returned when running or holding a game that is already held
+type HeldError struct {
+	Game string
+}
+
+func (e HeldError) Error() string {
+	return fmt.Sprintf("%s is held while its save files are changed", e.Game)
+}
+
+// ConfirmTimeoutError is returned when a game does not confirm a command in time
+type ConfirmTimeoutError struct {
+	Game    string
+	Command string
+	Timeout time.Duration
+}
+
+func (e ConfirmTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not confirm command [%s] after %s", e.Game, e.Command, e.Timeout)
+}
+
+// MaxRunningError is returned when starting a game would exceed the configured limit
+type MaxRunningError struct {
+	Max int
+}
+
+func (e MaxRunningError) Error() string {
+	return fmt.Sprintf("no more than %d games can run at once", e.Max)
+}
+
+// checkCanRun ensures the game does not conflict with running games, Client.mu must be held
+func (c *Client) checkCanRun(gameCfg *config.GameConfig) error {
+	// Get ports used by each running game
+	numRunning := 0
+	usedPorts := make(map[int32]string)
+	for _, g := range c.games {
+		if !g.isActive() {
+			continue
+		}
+		numRunning++
+		if runningCfg, ok := c.cfg.GetGameConfig(g.name); ok {
+			for _, port := range runningCfg.Ports {
+				usedPorts[port] = g.name
+			}
+		}
+	}
+
+	// Check limit on running games
+	if max := c.cfg.Settings.MaxRunningGames; max > 0 && numRunning >= max {
+		return MaxRunningError{Max: max}
+	}
+
+	// Check for shared ports
+	for _, port := range gameCfg.Ports {
+		if runningGame, ok := usedPorts[port]; ok {
+			return PortConflictError{Game: gameCfg.Name, RunningGame: runningGame, Port: port}
+		}
+	}
+
 	return nil
 }
 
+func gameKey(game string) string {
+	return strings.ToLower(game)
+}
+
+// Subscribe returns a channel of lifecycle events for all game servers
+func (c *Client) Subscribe() <-chan Event {
+	return c.events.subscribe()
+}
+
+// awaitReady waits for the ready pattern to be matched by the console watch, which must be started before the server
+func (c *Client) awaitReady(g *game, s *server, match <-chan string, cancel func()) {
+	defer cancel()
+
+	// Without a ready pattern the server is considered ready once started
+	if s.ready == nil {
+		c.markReady(g, s)
+		return
+	}
+
+	timeout := time.After(s.readyTimeout)
+	for {
+		select {
+		case <-match:
+			s.logger.Info("game server is ready")
+			c.markReady(g, s)
+			return
+
+		// Reported, but still watched for as a server slow to start may yet become ready
+		case <-timeout:
+			timeout = nil
+			s.logger.Error("game server did not become ready", zap.Duration("timeout", s.readyTimeout))
+			c.mu.Lock()
+			if g.server == s && g.state == StateStarting {
+				c.events.publish(Event{
+					Game:  g.name,
+					From:  StateStarting,
+					State: StateStarting,
+					Err:   ReadyTimeoutError{Game: g.name, Timeout: s.readyTimeout},
+				})
+			}
+			c.mu.Unlock()
+
+		// Server exited before becoming ready
+		case <-s.exited:
+			return
+		}
+	}
+}
+
+func (c *Client) markReady(g *game, s *server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Ignore if the server has since been stopped or replaced
+	if g.server == s && g.state == StateStarting {
+		c.transition(g, StateRunning, Event{})
+	}
+}
+
 type server struct {
-	name   string
-	logger *zap.Logger
-	run    *exec.Cmd
-	stop   string
-	msg    string
-	in     io.Writer
-	out    io.Reader
-	outErr io.Reader
+	name    string
+	logger  *zap.Logger
+	run     *exec.Cmd
+	stop    string
+	msg     string
+	cmd     commander
+	console *console
+
+	ready        *regexp.Regexp
+	readyTimeout time.Duration
+
+	// Restart policy
+	restartPolicy  string
+	maxRestarts    int
+	restartBackoff time.Duration
+	attempt        int // Number of restarts leading up to this server
+
+	// Process exit
+	exited   chan struct{}
+	exitCode int
+	waitErr  error
 }
 
 func newGameServer(cfg *config.Config, game string) (*server, error) {
@@ -110,6 +455,26 @@ func newGameServer(cfg *config.Config, game string) (*server, error) {
 		run:    exec.Command(gameCfg.Run.Command, gameCfg.Run.Args...),
 		stop:   gameCfg.Stop,
 		msg:    gameCfg.Message,
+
+		readyTimeout: ServerReadyTimeout,
+
+		restartPolicy:  gameCfg.Restart.Policy,
+		maxRestarts:    gameCfg.Restart.MaxAttempts,
+		restartBackoff: gameCfg.Restart.Backoff.Duration,
+
+		exited: make(chan struct{}),
+	}
+
+	// Get pattern signalling the server is ready to join
+	if pattern := gameCfg.ReadyPattern; pattern != "" {
+		ready, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ready pattern for game [%s]: %w", gameCfg.Name, err)
+		}
+		s.ready = ready
+	}
+	if timeout := gameCfg.ReadyTimeout.Duration; timeout > 0 {
+		s.readyTimeout = timeout
 	}
 
 	// Specify working directory
@@ -125,49 +490,76 @@ func newGameServer(cfg *config.Config, game string) (*server, error) {
 		s.run.Dir = dir
 	}
 
-	// Get input writer and output readers
+	// Input is kept open even when commands are sent over another transport,
+	// since some servers exit once their console input closes
 	in, err := s.run.StdinPipe()
 	if err != nil {
 		return nil, err
 	}
-	s.in = in
-	out, err := s.run.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	s.out = out
-	outErr, err := s.run.StderrPipe()
-	if err != nil {
+	s.cmd = newCommander(gameCfg, in)
+
+	// Route output through the console so it is always drained
+	if s.console, err = newConsole(s.logger, gameCfg.Name); err != nil {
+		in.Close()
 		return nil, err
 	}
-	s.outErr = outErr
+	s.run.Stdout = s.console.stdout()
+	s.run.Stderr = s.console.stderr()
 
 	return s, nil
 }
 
+func (s *server) start() error {
+	if err := s.run.Start(); err != nil {
+		s.console.close()
+		return err
+	}
+	s.console.start()
+	go s.wait()
+	return nil
+}
+
+// wait records the exit of the process, it must be the only caller of Wait
+func (s *server) wait() {
+	s.waitErr = s.run.Wait()
+	s.exitCode = exitCode(s.run.ProcessState)
+	s.cmd.close()
+	close(s.exited)
+}
+
+func (s *server) message(message string) error {
+	return s.cmd.send(fmt.Sprintf("%s %s", s.msg, message))
+}
+
 func (s *server) stopServer() error {
 	// Send shutdown warning and delay
-	warningMsg := fmt.Sprintf(ServerShutdownWarning, ServerShutdownDelay)
-	warningMsg = fmt.Sprintf("%s %s\n", s.msg, warningMsg)
-	s.in.Write([]byte(warningMsg))
+	if err := s.message(fmt.Sprintf(ServerShutdownWarning, ServerShutdownDelay)); err != nil {
+		s.logger.Error("could not send shutdown warning", zap.Error(err))
+	}
 	time.Sleep(ServerShutdownDelay)
 
-	// Try graceful shutdown
-	s.in.Write(append([]byte(s.stop), '\n'))
-	wait := make(chan error)
-	go func() {
-		wait <- s.run.Wait()
-	}()
+	// Try graceful shutdown, forcing shutdown on timeout if the command is lost
+	if err := s.cmd.send(s.stop); err != nil {
+		s.logger.Error("could not send stop command", zap.Error(err))
+	}
 
 	select {
 	// Await graceful shutdown
-	case err := <-wait:
+	case <-s.exited:
 		s.logger.Info("game server shutdown gracefully")
-		return err
+		return s.waitErr
 
 	// Force shutdown on timeout
 	case <-time.After(ServerShutdownTimeout):
 		s.logger.Error("could not shutdown game server gracefully, forcing shutdown")
-		return s.run.Process.Kill()
+	}
+
+	// Await the exit, so the process no longer holds its ports and save files once stopped
+	killErr := s.run.Process.Kill()
+	select {
+	case <-s.exited:
+		return killErr
+	case <-time.After(ServerKillTimeout):
+		return fmt.Errorf("game server did not exit %s after being killed: %w", ServerKillTimeout, killErr)
 	}
 }
@@ -1,16 +1,27 @@
 package gameserver
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
+	"game-server/internal/config"
+	"game-server/internal/gamelock"
 	"game-server/internal/testing/mockserver"
+	"game-server/pkg/query"
+	"game-server/pkg/rcon"
 )
 
 func Test_Run_and_Stop(t *testing.T) {
@@ -20,36 +31,38 @@ func Test_Run_and_Stop(t *testing.T) {
 	}(ServerShutdownDelay)
 	newDelay := 10 * time.Millisecond
 	ServerShutdownDelay = newDelay
-	
-	// Start mock server
+
+	// Record console output from mock server
+	logDir := t.TempDir()
+	t.Setenv(EnvLogDir, logDir)
 	testCfg := mockserver.GetConfig(t)
+	core, logs := observer.New(zap.InfoLevel)
+	testCfg.Logger = zap.New(core)
+
+	// Start mock server
 	c := New(testCfg)
 	require.NoError(t, c.Run(mockserver.GameName))
 	time.Sleep(10 * time.Millisecond)
-
-	// Record stdout from mock server
-	var out []string
-	go func() {
-		buf := bufio.NewReader(c.running.out)
-		for {
-			line, err := buf.ReadString('\n')
-			if err != nil {
-				return
-			}
-			out = append(out, line)
-		}
-	}()
+	console := runningServer(c, mockserver.GameName).console
 
 	// Stop mock server
-	require.NoError(t, c.Stop())
+	require.NoError(t, c.Stop(mockserver.GameName))
 
 	// Check running status was cleared
-	assert.Nil(t, c.running)
+	assert.False(t, c.IsRunning(mockserver.GameName))
+	assert.Equal(t, StateStopped, c.State(mockserver.GameName))
+
+	// Wait for remaining output to be drained
+	select {
+	case <-console.done:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Console output was not drained")
+	}
 
 	// Check for warning message and graceful shutdown
 	didWarningMsg := false
 	didGracefulShutdown := false
-	for _, line := range out {
+	for _, line := range consoleLines(logs) {
 		if strings.Contains(line, fmt.Sprintf(ServerShutdownWarning, newDelay)) {
 			didWarningMsg = true
 		} else if strings.Contains(line, mockserver.ShutdownResponse) {
@@ -58,4 +71,597 @@ func Test_Run_and_Stop(t *testing.T) {
 	}
 	assert.True(t, didWarningMsg, "Did not get shutdown warning message")
 	assert.True(t, didGracefulShutdown, "Server did not shutdown gracefully")
+
+	// Check output was persisted to the game's log file
+	logFile, err := os.ReadFile(filepath.Join(logDir, mockserver.GameName+".log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(logFile), mockserver.ShutdownResponse)
+}
+
+func Test_Stop_RCON(t *testing.T) {
+	// Override shutdown delay and timeout
+	defer func(origDelay time.Duration, origTimeout time.Duration) {
+		ServerShutdownDelay = origDelay
+		ServerShutdownTimeout = origTimeout
+	}(ServerShutdownDelay, ServerShutdownTimeout)
+	ServerShutdownDelay = time.Millisecond
+	ServerShutdownTimeout = 100 * time.Millisecond
+
+	// Send commands to a fake RCON server, which cannot stop the mock server
+	rconServer := rcon.StartFakeServer(t, "password")
+	_, portStr, err := net.SplitHostPort(rconServer.Addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+	gameCfg.CommandTransport = config.TransportRCON
+	gameCfg.RCON.Port = int32(port)
+	gameCfg.RCON.Password = "password"
+
+	// Start mock server
+	c := New(testCfg)
+	require.NoError(t, c.Run(mockserver.GameName))
+	require.Eventually(t, func() bool {
+		return c.State(mockserver.GameName) == StateRunning
+	}, 30*time.Second, 10*time.Millisecond)
+
+	// Stop is forced once the stop command has no effect, only returning once the process has exited
+	s := runningServer(c, mockserver.GameName)
+	c.Stop(mockserver.GameName)
+	assert.Equal(t, StateStopped, c.State(mockserver.GameName))
+	select {
+	case <-s.exited:
+	default:
+		assert.Fail(t, "game server still running once stopped")
+	}
+
+	// Check warning and stop commands were sent over RCON
+	warningMsg := fmt.Sprintf("%s %s", mockserver.MessageCommand, fmt.Sprintf(ServerShutdownWarning, ServerShutdownDelay))
+	assert.Equal(t, []string{warningMsg, mockserver.StopCommand}, rconServer.Commands())
+}
+
+func Test_rconCommander_send(t *testing.T) {
+	tests := []struct {
+		name        string
+		stale       bool
+		command     string
+		expCommands []string
+		expErr      bool
+	}{
+		{
+			name:        "Happy path - Reconnects when stale",
+			stale:       true,
+			command:     "say hello",
+			expCommands: []string{"say hello"},
+		},
+		{
+			name:        "Sad path - Not resent once received",
+			command:     rcon.FakeDropCommand,
+			expCommands: []string{rcon.FakeDropCommand},
+			expErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rconServer := rcon.StartFakeServer(t, "password")
+			client, err := rcon.Dial(rconServer.Addr, "password")
+			require.NoError(t, err)
+			if tt.stale {
+				client.Close()
+			}
+			c := &rconCommander{address: rconServer.Addr, password: "password", client: client}
+			defer c.close()
+
+			err = c.send(tt.command)
+
+			if tt.expErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expCommands, rconServer.Commands())
+		})
+	}
+}
+
+func Test_Message(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	core, logs := observer.New(zap.InfoLevel)
+	testCfg.Logger = zap.New(core)
+
+	// Game must be running
+	c := New(testCfg)
+	assert.Error(t, c.Message(mockserver.GameName, "hello"))
+
+	require.NoError(t, c.Run(mockserver.GameName))
+	defer c.Stop(mockserver.GameName)
+
+	require.NoError(t, c.Message(mockserver.GameName, "hello"))
+	assert.Eventually(t, func() bool {
+		return logs.FilterField(zap.String("line", "hello")).Len() > 0
+	}, 30*time.Second, 10*time.Millisecond)
+}
+
+func Test_Command(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	confirm := regexp.MustCompile("^Saved the game$")
+
+	// Game must be running
+	c := New(testCfg)
+	assert.Error(t, c.Command(context.Background(), mockserver.GameName, mockserver.MessageCommand+" Saved the game", confirm, time.Second))
+
+	require.NoError(t, c.Run(mockserver.GameName))
+	defer c.Stop(mockserver.GameName)
+
+	tests := []struct {
+		name    string
+		command string
+		confirm *regexp.Regexp
+		timeout time.Duration
+		expErr  bool
+	}{
+		{
+			name:    "Happy path",
+			command: mockserver.MessageCommand + " Saved the game",
+			confirm: confirm,
+			timeout: 30 * time.Second,
+		},
+		{
+			name:    "Happy path - No confirmation",
+			command: mockserver.MessageCommand + " hello",
+		},
+		{
+			name:    "Sad path - Not confirmed",
+			command: mockserver.MessageCommand + " Could not save",
+			confirm: confirm,
+			timeout: 500 * time.Millisecond,
+			expErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Command(context.Background(), mockserver.GameName, tt.command, tt.confirm, tt.timeout)
+
+			if tt.expErr {
+				assert.ErrorAs(t, err, &ConfirmTimeoutError{})
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	// Waiting for confirmation ends with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err := c.Command(ctx, mockserver.GameName, mockserver.MessageCommand+" Could not save", confirm, 30*time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_Run_HighOutput(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	core, logs := observer.New(zap.InfoLevel)
+	testCfg.Logger = zap.New(core)
+
+	// Start mock server
+	c := New(testCfg)
+	require.NoError(t, c.Run(mockserver.GameName))
+	defer c.Stop(mockserver.GameName)
+
+	// Write far more than a pipe buffer to both streams, followed by a marker line
+	marker := "finished spamming"
+	s := runningServer(c, mockserver.GameName)
+	require.NoError(t, s.cmd.send(fmt.Sprintf("%s %d", mockserver.SpamCommand, 20000)))
+	require.NoError(t, s.cmd.send(fmt.Sprintf("%s %s", mockserver.MessageCommand, marker)))
+
+	// Marker is only printed if the server never blocked on a full pipe
+	assert.Eventually(t, func() bool {
+		return logs.FilterField(zap.String("line", marker)).Len() > 0 &&
+			logs.FilterField(zap.String("stream", stderrStream)).Len() >= 20000
+	}, 30*time.Second, 10*time.Millisecond, "Server blocked on output")
+}
+
+func consoleLines(logs *observer.ObservedLogs) []string {
+	var lines []string
+	for _, entry := range logs.FilterMessage("console output").All() {
+		lines = append(lines, entry.ContextMap()["line"].(string))
+	}
+	return lines
+}
+
+func Test_Run_Ready(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+
+	tests := []struct {
+		name          string
+		overrideReady bool
+		readyPattern  string
+		readyTimeout  time.Duration
+		expState      State
+		expErr        error
+	}{
+		{
+			name:     "Happy path - Ready pattern logged",
+			expState: StateRunning,
+		},
+		{
+			name:          "Happy path - No ready pattern",
+			overrideReady: true,
+			expState:      StateRunning,
+		},
+		{
+			name:          "Sad path - Ready pattern not logged before timeout",
+			overrideReady: true,
+			readyPattern:  "^Never printed$",
+			readyTimeout:  100 * time.Millisecond,
+			expState:      StateStarting,
+			expErr:        ReadyTimeoutError{Game: mockserver.GameName, Timeout: 100 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCfg := mockserver.GetConfig(t)
+			gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+			if tt.overrideReady {
+				gameCfg.ReadyPattern = tt.readyPattern
+				gameCfg.ReadyTimeout.Duration = tt.readyTimeout
+			}
+
+			c := New(testCfg)
+			events := c.Subscribe()
+			require.NoError(t, c.Run(mockserver.GameName))
+			defer c.Stop(mockserver.GameName)
+
+			// Starting is always reported first
+			e := awaitEvent(t, events)
+			assert.Equal(t, Event{Game: mockserver.GameName, From: StateStopped, State: StateStarting}, e)
+
+			// Followed by readiness or a timeout
+			e = awaitEvent(t, events)
+			assert.Equal(t, Event{Game: mockserver.GameName, From: StateStarting, State: tt.expState, Err: tt.expErr}, e)
+		})
+	}
+}
+
+func Test_Run_ReadyAfterTimeout(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+	gameCfg.ReadyPattern = "^Ready at last$"
+	gameCfg.ReadyTimeout.Duration = 100 * time.Millisecond
+
+	c := New(testCfg)
+	events := c.Subscribe()
+	require.NoError(t, c.Run(mockserver.GameName))
+	defer c.Stop(mockserver.GameName)
+	assert.Equal(t, StateStarting, awaitEvent(t, events).State)
+	assert.ErrorAs(t, awaitEvent(t, events).Err, &ReadyTimeoutError{})
+
+	// Server slow to start still becomes running
+	require.NoError(t, c.Command(context.Background(), mockserver.GameName, mockserver.MessageCommand+" Ready at last", nil, 0))
+	assert.Equal(t, Event{Game: mockserver.GameName, From: StateStarting, State: StateRunning}, awaitEvent(t, events))
+}
+
+func awaitEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(30 * time.Second):
+		require.Fail(t, "Did not receive game server event")
+	}
+	return Event{}
+}
+
+func Test_Run_Crash(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+
+	crashCmd := fmt.Sprintf("%s 3", mockserver.CrashCommand)
+	tests := []struct {
+		name        string
+		policy      string
+		maxAttempts int
+		crash       func(c *Client)
+		expExitCode int
+		expRestarts int
+	}{
+		{
+			name:        "Happy path - Never restart",
+			policy:      config.RestartNever,
+			maxAttempts: 3,
+			crash:       func(c *Client) { runningServer(c, mockserver.GameName).cmd.send(crashCmd) },
+			expExitCode: 3,
+		},
+		{
+			name:        "Happy path - Restart on failure until max attempts",
+			policy:      config.RestartOnFailure,
+			maxAttempts: 2,
+			crash:       func(c *Client) { runningServer(c, mockserver.GameName).cmd.send(crashCmd) },
+			expExitCode: 3,
+			expRestarts: 2,
+		},
+		{
+			name:        "Happy path - Killed by signal",
+			policy:      config.RestartNever,
+			crash:       func(c *Client) { runningServer(c, mockserver.GameName).run.Process.Kill() },
+			expExitCode: 137,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCfg := mockserver.GetConfig(t)
+			gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+			gameCfg.Restart.Policy = tt.policy
+			gameCfg.Restart.MaxAttempts = tt.maxAttempts
+			gameCfg.Restart.Backoff.Duration = 10 * time.Millisecond
+
+			c := New(testCfg)
+			events := c.Subscribe()
+			require.NoError(t, c.Run(mockserver.GameName))
+
+			for attempt := 0; attempt <= tt.expRestarts; attempt++ {
+				// Crash once ready
+				require.Equal(t, StateStarting, awaitEvent(t, events).State)
+				require.Equal(t, StateRunning, awaitEvent(t, events).State)
+				tt.crash(c)
+
+				// Check crash is reported along with any restart
+				expEvent := Event{Game: mockserver.GameName, From: StateRunning, State: StateCrashed, ExitCode: tt.expExitCode}
+				if attempt < tt.expRestarts {
+					expEvent.Attempt = attempt + 1
+					expEvent.MaxAttempts = tt.maxAttempts
+				}
+				assert.Equal(t, expEvent, awaitEvent(t, events))
+			}
+
+			// Check running status was cleared once no restarts remain
+			assert.False(t, c.IsRunning(mockserver.GameName))
+			assert.Equal(t, StateCrashed, c.State(mockserver.GameName))
+		})
+	}
+}
+
+func Test_Stop_CancelsRestart(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+	c := New(testCfg)
+	events := c.Subscribe()
+
+	// Simulate a pending restart
+	g := c.getGame(mockserver.GameName)
+	g.state = StateCrashed
+	g.restart = &restart{attempt: 1}
+	g.restart.timer = time.AfterFunc(time.Hour, func() { c.restart(g, g.restart) })
+
+	assert.True(t, c.IsRunning(mockserver.GameName))
+	assert.Equal(t, []string{mockserver.GameName}, c.Running())
+
+	require.NoError(t, c.Stop(mockserver.GameName))
+
+	assert.False(t, c.IsRunning(mockserver.GameName))
+	assert.Equal(t, Event{Game: mockserver.GameName, From: StateCrashed, State: StateStopped}, awaitEvent(t, events))
+}
+
+func Test_Hold(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	c := New(testCfg)
+
+	// Held game cannot be run or held again
+	release, err := c.Hold(mockserver.GameName)
+	require.NoError(t, err)
+	assert.Equal(t, HeldError{Game: mockserver.GameName}, c.Run(mockserver.GameName))
+	_, err = c.Hold(mockserver.GameName)
+	assert.Equal(t, HeldError{Game: mockserver.GameName}, err)
+
+	// Released game can be run, but not held while running
+	release()
+	release()
+	require.NoError(t, c.Run(mockserver.GameName))
+	_, err = c.Hold(mockserver.GameName)
+	assert.Error(t, err)
+	require.NoError(t, c.Stop(mockserver.GameName))
+
+	// Game locked by another process, such as a restore, cannot be run
+	gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+	lock, err := gamelock.Acquire(gameCfg.WorkingDir, mockserver.GameName)
+	require.NoError(t, err)
+	assert.Equal(t, HeldError{Game: mockserver.GameName}, c.Run(mockserver.GameName))
+	require.NoError(t, lock.Release())
+}
+
+func Test_Run_Locked(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("open files cannot be counted on this platform")
+	}
+	openFiles := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	logDir := t.TempDir()
+	t.Setenv(EnvLogDir, logDir)
+	testCfg := mockserver.GetConfig(t)
+	c := New(testCfg)
+
+	// Game locked by another process is refused without opening its pipes or logs
+	gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+	lock, err := gamelock.Acquire(gameCfg.WorkingDir, mockserver.GameName)
+	require.NoError(t, err)
+	defer lock.Release()
+
+	before := openFiles()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, HeldError{Game: mockserver.GameName}, c.Run(mockserver.GameName))
+	}
+	assert.Equal(t, before, openFiles())
+	entries, err := os.ReadDir(logDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, StateStopped, c.State(mockserver.GameName))
+}
+
+func Test_Run_Multiple(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+
+	// Setup games where the first and last share a port
+	gameA, gameB, gameC := "GameA", "GameB", "GameC"
+	testCfg := mockserver.GetMultiGameConfig(t, gameA, gameB, gameC)
+	for game, port := range map[string]int32{gameA: 1000, gameB: 2000, gameC: 1000} {
+		gameCfg, _ := testCfg.GetGameConfig(game)
+		gameCfg.Ports = []int32{port}
+	}
+	testCfg.Settings.MaxRunningGames = 2
+
+	c := New(testCfg)
+	require.NoError(t, c.Run(gameA))
+	defer c.Stop(gameA)
+	require.NoError(t, c.Run(gameB))
+	defer c.Stop(gameB)
+	assert.Equal(t, []string{gameA, gameB}, c.Running())
+
+	// Check game cannot be started twice
+	assert.ErrorAs(t, c.Run(gameA), &TransitionError{})
+
+	// Check limit on running games
+	assert.Equal(t, MaxRunningError{Max: 2}, c.Run(gameC))
+
+	// Check port conflict
+	testCfg.Settings.MaxRunningGames = 0
+	assert.Equal(t, PortConflictError{Game: gameC, RunningGame: gameA, Port: 1000}, c.Run(gameC))
+
+	// Check games are stopped independently
+	require.NoError(t, c.Stop(gameA))
+	assert.False(t, c.IsRunning(gameA))
+	assert.True(t, c.IsRunning(gameB))
+	assert.Equal(t, TransitionError{Game: gameA, From: StateStopped, To: StateStopping}, c.Stop(gameA))
+
+	// Port is free once conflicting game is stopped
+	require.NoError(t, c.Run(gameC))
+	require.NoError(t, c.Stop(gameC))
+}
+
+func runningServer(c *Client, game string) *server {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.games[gameKey(game)].server
+}
+
+func Test_Client_ConcurrentLifecycle(t *testing.T) {
+	// Override shutdown delay
+	defer func(origDelay time.Duration) {
+		ServerShutdownDelay = origDelay
+	}(ServerShutdownDelay)
+	ServerShutdownDelay = 10 * time.Millisecond
+
+	t.Setenv(EnvLogDir, t.TempDir())
+	testCfg := mockserver.GetConfig(t)
+	c := New(testCfg)
+	events := c.Subscribe()
+
+	// Run concurrently, only one should start the game
+	const numCallers = 5
+	errs := make(chan error, numCallers)
+	for i := 0; i < numCallers; i++ {
+		go func() {
+			errs <- c.Run(mockserver.GameName)
+			c.IsRunning(mockserver.GameName)
+			c.Running()
+		}()
+	}
+	assertOneSucceeded(t, errs, numCallers)
+	assert.Equal(t, StateStarting, awaitEvent(t, events).State)
+	assert.Equal(t, StateRunning, awaitEvent(t, events).State)
+
+	// Stop concurrently, only one should stop the game
+	for i := 0; i < numCallers; i++ {
+		go func() {
+			errs <- c.Stop(mockserver.GameName)
+			c.State(mockserver.GameName)
+		}()
+	}
+	assertOneSucceeded(t, errs, numCallers)
+	assert.Equal(t, Event{Game: mockserver.GameName, From: StateRunning, State: StateStopping}, awaitEvent(t, events))
+	assert.Equal(t, Event{Game: mockserver.GameName, From: StateStopping, State: StateStopped}, awaitEvent(t, events))
+	assert.Equal(t, StateStopped, c.State(mockserver.GameName))
+}
+
+func assertOneSucceeded(t *testing.T, errs <-chan error, numCallers int) {
+	succeeded := 0
+	for i := 0; i < numCallers; i++ {
+		if err := <-errs; err == nil {
+			succeeded++
+		} else {
+			assert.ErrorAs(t, err, &TransitionError{})
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func Test_Status(t *testing.T) {
+	testCfg := mockserver.GetConfig(t)
+	c := New(testCfg)
+
+	// Mock game has no query configured
+	_, err := c.Status(mockserver.GameName)
+	assert.ErrorIs(t, err, ErrNoQuery)
+
+	// Query requires the game to be running
+	gameCfg, _ := testCfg.GetGameConfig(mockserver.GameName)
+	gameCfg.Query.Protocol = query.ProtocolA2S
+	gameCfg.Query.Port = 27015
+	_, err = c.Status(mockserver.GameName)
+	assert.ErrorContains(t, err, "game is not running")
 }
@@ -0,0 +1,117 @@
+package gameserver
+
+import (
+	"fmt"
+
+	"game-server/internal/gamelock"
+)
+
+type State int
+
+const (
+	StateStopped State = iota
+	StateStarting
+	StateRunning
+	StateStopping
+	StateCrashed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateCrashed:
+		return "crashed"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// Valid transitions from each state
+var transitions = map[State][]State{
+	StateStopped:  {StateStarting},
+	StateStarting: {StateRunning, StateStopping, StateStopped, StateCrashed},
+	StateRunning:  {StateStopping, StateStopped, StateCrashed},
+	StateStopping: {StateStopped},
+	StateCrashed:  {StateStarting, StateStopped},
+}
+
+// TransitionError is returned when a game cannot move from its current state to the requested one
+type TransitionError struct {
+	Game string
+	From State
+	To   State
+}
+
+func (e TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot go from %s to %s", e.Game, e.From, e.To)
+}
+
+// game tracks the lifecycle of a single game, all fields are guarded by Client.mu
+type game struct {
+	name    string
+	state   State
+	server  *server        // Process for the current run, nil when stopped
+	restart *restart       // Pending restart after a crash
+	held    bool           // Kept from running, see Client.Hold
+	lock    *gamelock.Lock // Held on the game in its working directory while active
+}
+
+// isActive reports whether the game has a process or is about to have one
+func (g *game) isActive() bool {
+	switch g.state {
+	case StateStarting, StateRunning, StateStopping:
+		return true
+	case StateCrashed:
+		return g.restart != nil
+	}
+	return false
+}
+
+func canTransition(from State, to State) bool {
+	for _, valid := range transitions[from] {
+		if valid == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the game to a new state and notifies subscribers, Client.mu must be held
+func (c *Client) transition(g *game, to State, e Event) error {
+	if !canTransition(g.state, to) {
+		return TransitionError{Game: g.name, From: g.state, To: to}
+	}
+
+	e.Game, e.From, e.State = g.name, g.state, to
+	g.state = to
+	if !g.isActive() {
+		g.unlock()
+	}
+	c.events.publish(e)
+	return nil
+}
+
+// unlock releases the working directory of the game, if locked
+func (g *game) unlock() {
+	if g.lock != nil {
+		g.lock.Release()
+		g.lock = nil
+	}
+}
+
+// getGame gets the lifecycle of a game, adding it as stopped if not yet tracked, Client.mu must be held
+func (c *Client) getGame(name string) *game {
+	key := gameKey(name)
+	g, ok := c.games[key]
+	if !ok {
+		g = &game{name: name, state: StateStopped}
+		c.games[key] = g
+	}
+	return g
+}
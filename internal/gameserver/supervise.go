@@ -0,0 +1,139 @@
+package gameserver
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/config"
+)
+
+var RestartBackoff = 5 * time.Second // Default when not set by game config
+
+type restart struct {
+	attempt int
+	timer   *time.Timer
+}
+
+// RestartError is reported when a crashed game server could not be started again
+type RestartError struct {
+	Game string
+	Err  error
+}
+
+func (e RestartError) Error() string {
+	return fmt.Sprintf("could not restart %s: %s", e.Game, e.Err)
+}
+
+func (e RestartError) Unwrap() error {
+	return e.Err
+}
+
+// launch starts the server process and its supervisor, Client.mu must be held
+func (c *Client) launch(g *game, s *server) error {
+	// Watch before starting, so a server that is quickly ready is not missed
+	var ready <-chan string
+	cancelReady := func() {}
+	if s.ready != nil {
+		ready, cancelReady = s.console.watch(s.ready)
+	}
+
+	if err := s.start(); err != nil {
+		cancelReady()
+		return err
+	}
+	g.server = s
+	c.transition(g, StateStarting, Event{Attempt: s.attempt})
+
+	go c.awaitReady(g, s, ready, cancelReady)
+	go c.supervise(g, s)
+	return nil
+}
+
+// supervise awaits the exit of the server process and applies its restart policy if it was not requested
+func (c *Client) supervise(g *game, s *server) {
+	<-s.exited
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Exit was requested through Stop, or the server was replaced
+	if g.server != s || g.state == StateStopping {
+		return
+	}
+	g.server = nil
+
+	// A clean exit is not a crash and is never restarted
+	if s.exitCode == 0 {
+		s.logger.Info("game server exited")
+		c.transition(g, StateStopped, Event{})
+		return
+	}
+
+	e := Event{ExitCode: s.exitCode}
+	if s.restartPolicy != config.RestartOnFailure || s.attempt >= s.maxRestarts {
+		s.logger.Error("game server crashed", zap.Int("exitCode", s.exitCode))
+		c.transition(g, StateCrashed, e)
+		return
+	}
+
+	// Schedule restart with exponential backoff
+	r := &restart{attempt: s.attempt + 1}
+	backoff := s.restartBackoff
+	if backoff <= 0 {
+		backoff = RestartBackoff
+	}
+	delay := backoff * time.Duration(1<<(r.attempt-1))
+	e.Attempt, e.MaxAttempts = r.attempt, s.maxRestarts
+
+	s.logger.Error(
+		"game server crashed, scheduling restart",
+		zap.Int("exitCode", s.exitCode),
+		zap.Int("attempt", r.attempt),
+		zap.Duration("delay", delay),
+	)
+	g.restart = r
+	c.transition(g, StateCrashed, e)
+	r.timer = time.AfterFunc(delay, func() {
+		c.restart(g, r)
+	})
+}
+
+func (c *Client) restart(g *game, r *restart) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Restart was cancelled or superseded
+	if g.restart != r {
+		return
+	}
+	g.restart = nil
+
+	// Other games may have started in the meantime
+	var err error
+	gameCfg, ok := c.cfg.GetGameConfig(g.name)
+	if !ok {
+		err = fmt.Errorf("no configuration for game: [%s]", g.name)
+	} else if err = c.checkCanRun(gameCfg); err == nil {
+		var s *server
+		if s, err = newGameServer(c.cfg, g.name); err == nil {
+			s.attempt = r.attempt
+			err = c.launch(g, s)
+		}
+	}
+	if err != nil {
+		c.logger.Error("could not restart game server", zap.Error(err), zap.String("game", g.name))
+		c.transition(g, StateStopped, Event{Err: RestartError{Game: g.name, Err: err}})
+	}
+}
+
+// exitCode gets the exit code of the process, following shell convention for signals
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
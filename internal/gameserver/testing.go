@@ -1,12 +1,26 @@
 package gameserver
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"game-server/pkg/query"
+)
 
 const (
 	LoadMethod      = "Load"
 	RunMethod       = "Run"
 	IsRunningMethod = "IsRunning"
+	RunningMethod   = "Running"
+	HoldMethod      = "Hold"
 	StopMethod      = "Stop"
+	MessageMethod   = "Message"
+	CommandMethod   = "Command"
+	StatusMethod    = "Status"
+	SubscribeMethod = "Subscribe"
 )
 
 // Ensure MockClient implements ClientIFace
@@ -21,12 +35,51 @@ func (m *MockClient) Run(game string) error {
 	return args.Error(0)
 }
 
-func (m *MockClient) IsRunning() (string, bool) {
-	args := m.Called()
-	return args.String(0), args.Bool(1)
+func (m *MockClient) IsRunning(game string) bool {
+	args := m.Called(game)
+	return args.Bool(0)
 }
 
-func (m *MockClient) Stop() error {
+func (m *MockClient) Running() []string {
 	args := m.Called()
+	if games := args.Get(0); games != nil {
+		return games.([]string)
+	}
+	return nil
+}
+
+func (m *MockClient) Hold(game string) (func(), error) {
+	args := m.Called(game)
+	if release := args.Get(0); release != nil {
+		return release.(func()), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockClient) Stop(game string) error {
+	args := m.Called(game)
 	return args.Error(0)
 }
+
+func (m *MockClient) Message(game string, message string) error {
+	args := m.Called(game, message)
+	return args.Error(0)
+}
+
+func (m *MockClient) Command(ctx context.Context, game string, command string, confirm *regexp.Regexp, timeout time.Duration) error {
+	args := m.Called(ctx, game, command, confirm, timeout)
+	return args.Error(0)
+}
+
+func (m *MockClient) Status(game string) (*query.Status, error) {
+	args := m.Called(game)
+	if status := args.Get(0); status != nil {
+		return status.(*query.Status), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockClient) Subscribe() <-chan Event {
+	args := m.Called()
+	return args.Get(0).(<-chan Event)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"game-server/internal/backup"
+	"game-server/internal/config"
+	discordbot "game-server/internal/discord/bot"
+	"game-server/internal/gameserver"
+)
+
+const (
+	loggerName = "backup-scheduler"
+
+	// Used when not set by the game config
+	defaultSavedTimeout = time.Minute
+)
+
+// backupScheduler backs up each running game at the interval set by its config,
+// and verifies the latest backup of every game at the interval set by the service
+type backupScheduler struct {
+	cfg        *config.Config
+	logger     *zap.Logger
+	gameClient gameserver.ClientIFace
+	backup     backup.ClientIFace
+	botServer  discordbot.ServerIFace
+
+	mu        sync.Mutex
+	schedules map[string]chan struct{} // Closed to end the schedule of a game
+	done      chan struct{}            // Closed to end verification
+	stopped   bool
+	wg        sync.WaitGroup
+
+	// Cancelled to abandon any backup in progress, and any verification along with it
+	ctx          context.Context
+	cancelRun    context.CancelFunc
+	verifyCtx    context.Context
+	cancelVerify context.CancelFunc
+
+	// Backups and verification share the backup client, so only one runs at a time
+	runMu sync.Mutex
+}
+
+func newBackupScheduler(
+	cfg *config.Config,
+	gameClient gameserver.ClientIFace,
+	backupClient backup.ClientIFace,
+	botServer discordbot.ServerIFace,
+) *backupScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	verifyCtx, cancelVerify := context.WithCancel(ctx)
+	return &backupScheduler{
+		cfg:          cfg,
+		logger:       cfg.Logger.Named(loggerName),
+		gameClient:   gameClient,
+		backup:       backupClient,
+		botServer:    botServer,
+		schedules:    make(map[string]chan struct{}),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancelRun:    cancel,
+		verifyCtx:    verifyCtx,
+		cancelVerify: cancelVerify,
+	}
+}
+
+// startVerify schedules verification of the latest backups, if set by the config
+func (b *backupScheduler) startVerify() {
+	interval := b.cfg.Settings.VerifyInterval.Duration
+	if interval <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Never start verifying once stopped, even with a tick pending
+				select {
+				case <-b.done:
+					return
+				default:
+				}
+				b.verify()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+}
+
+// update schedules backups once a game is running, ending them when it is not
+func (b *backupScheduler) update(e gameserver.Event) {
+	if e.State == e.From {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	end, scheduled := b.schedules[e.Game]
+	if e.State != gameserver.StateRunning {
+		if scheduled {
+			close(end)
+			delete(b.schedules, e.Game)
+		}
+		return
+	}
+
+	gameCfg, ok := b.cfg.GetGameConfig(e.Game)
+	if !ok || gameCfg.SessionBackup.Interval.Duration <= 0 || scheduled || b.stopped {
+		return
+	}
+	end = make(chan struct{})
+	b.schedules[e.Game] = end
+	b.wg.Add(1)
+	go b.schedule(gameCfg, end)
+}
+
+// stop ends all schedules, waiting for any backup in progress but abandoning any verification
+func (b *backupScheduler) stop() {
+	b.mu.Lock()
+	if !b.stopped {
+		b.stopped = true
+		close(b.done)
+		b.cancelVerify()
+	}
+	for game, end := range b.schedules {
+		close(end)
+		delete(b.schedules, game)
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	b.cancelRun()
+}
+
+// cancel ends all schedules, abandoning any backup in progress rather than waiting for it to finish
+func (b *backupScheduler) cancel() {
+	b.cancelRun()
+	b.stop()
+}
+
+func (b *backupScheduler) schedule(gameCfg *config.GameConfig, end <-chan struct{}) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(gameCfg.SessionBackup.Interval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Never start a backup once ended, even with a tick pending
+			select {
+			case <-end:
+				return
+			default:
+			}
+			b.run(gameCfg)
+		case <-end:
+			return
+		}
+	}
+}
+
+// run has the game write its save with autosave paused, then backs it up
+func (b *backupScheduler) run(gameCfg *config.GameConfig) {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+
+	logger := b.logger.With(zap.String("game", gameCfg.Name))
+	sessionBackup := gameCfg.SessionBackup
+
+	// Stop the game writing its save while it is uploaded, resuming whatever happens
+	if sessionBackup.PauseAutosave != "" {
+		if err := b.gameClient.Command(b.ctx, gameCfg.Name, sessionBackup.PauseAutosave, nil, 0); err != nil {
+			logger.Error("could not pause autosave, skipping backup", zap.Error(err))
+			return
+		}
+	}
+	if sessionBackup.ResumeAutosave != "" {
+		defer func() {
+			// Resumed even once cancelled, as the game keeps running
+			if err := b.gameClient.Command(context.Background(), gameCfg.Name, sessionBackup.ResumeAutosave, nil, 0); err != nil {
+				logger.Error("could not resume autosave", zap.Error(err))
+			}
+		}()
+	}
+
+	if sessionBackup.Save != "" && b.ctx.Err() == nil {
+		var saved *regexp.Regexp
+		if sessionBackup.SavedPattern != "" {
+			saved = regexp.MustCompile(sessionBackup.SavedPattern) // Validated by the config
+		}
+		timeout := sessionBackup.SavedTimeout.Duration
+		if timeout <= 0 {
+			timeout = defaultSavedTimeout
+		}
+		if err := b.gameClient.Command(b.ctx, gameCfg.Name, sessionBackup.Save, saved, timeout); errors.Is(err, context.Canceled) {
+			return
+		} else if err != nil {
+			logger.Error("could not save game, skipping backup", zap.Error(err))
+			return
+		}
+	}
+
+	// Abandoned once cancelled, though autosave is still resumed
+	if b.ctx.Err() != nil {
+		return
+	}
+	logger.Info("backing up running game")
+	if err := b.backup.BackupGame(b.ctx, gameCfg.Name); errors.Is(err, context.Canceled) {
+		logger.Info("backup cancelled")
+	} else if err != nil {
+		logger.Error("error encountered backing up save data", zap.Error(err))
+	}
+}
+
+// verify checks the latest backup of each game, announcing any that do not match their manifest
+// or could not be checked, such as an incomplete backup
+func (b *backupScheduler) verify() {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+
+	for _, game := range b.cfg.GetGameNames() {
+		err := b.backup.Verify(b.verifyCtx, game, time.Time{})
+		var verifyErr backup.VerifyError
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			return
+		case errors.As(err, &verifyErr):
+			b.logger.Error("backup failed verification", zap.String("game", game), zap.Error(err))
+			b.botServer.Announce(fmt.Sprintf(
+				"The %s backup from %s failed verification, %d file(s) do not match", verifyErr.Game, verifyErr.Date, len(verifyErr.Failures),
+			))
+
+		// Games may not have a backup to verify yet
+		case errors.As(err, &backup.NoBackupError{}):
+			b.logger.Info("no backup to verify", zap.String("game", game))
+
+		// Backups made before they had a manifest cannot be checked, but are not at fault
+		case errors.As(err, &backup.NoManifestError{}):
+			b.logger.Info("latest backup has no manifest to verify against", zap.String("game", game), zap.Error(err))
+
+		default:
+			b.logger.Error("could not verify backup", zap.String("game", game), zap.Error(err))
+			b.botServer.Announce(fmt.Sprintf("The latest %s backup could not be verified, it may be incomplete or unreadable", game))
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"game-server/internal/backup"
+	"game-server/internal/config"
+	discordbot "game-server/internal/discord/bot"
+	"game-server/internal/gameserver"
+)
+
+var schedulerConfigFile = []byte(`[
+	{
+		"name": "Commands",
+		"session_backup": {
+			"interval": "10ms",
+			"pause_autosave": "save-off",
+			"save": "save-all",
+			"saved_pattern": "^Saved the game$",
+			"saved_timeout": "30s",
+			"resume_autosave": "save-on"
+		}
+	},
+	{
+		"name": "NoCommands",
+		"session_backup": {
+			"interval": "10ms"
+		}
+	},
+	{
+		"name": "AlsoNoCommands",
+		"session_backup": {
+			"interval": "10ms"
+		}
+	},
+	{
+		"name": "NotScheduled"
+	}
+]`)
+
+func Test_backupScheduler_run(t *testing.T) {
+	cfg := config.NewTestConfig(t, schedulerConfigFile)
+
+	tests := []struct {
+		name     string
+		game     string
+		saveErr  error
+		expCalls []string
+	}{
+		{
+			name:     "Happy path",
+			game:     "Commands",
+			expCalls: []string{"save-off", "save-all", backup.BackupGameMethod, "save-on"},
+		},
+		{
+			name:     "Happy path - No commands",
+			game:     "NoCommands",
+			expCalls: []string{backup.BackupGameMethod},
+		},
+		{
+			name:     "Sad path - Save not confirmed",
+			game:     "Commands",
+			saveErr:  gameserver.ConfirmTimeoutError{Game: "Commands", Command: "save-all", Timeout: 30 * time.Second},
+			expCalls: []string{"save-off", "save-all", "save-on"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			record := func(args mock.Arguments) {
+				calls = append(calls, args.String(2))
+			}
+
+			gameClient := &gameserver.MockClient{}
+			gameClient.On(gameserver.CommandMethod, mock.Anything, tt.game, "save-off", (*regexp.Regexp)(nil), time.Duration(0)).Run(record).Return(nil)
+			gameClient.On(gameserver.CommandMethod, mock.Anything, tt.game, "save-all", regexp.MustCompile("^Saved the game$"), 30*time.Second).Run(record).Return(tt.saveErr)
+			gameClient.On(gameserver.CommandMethod, mock.Anything, tt.game, "save-on", (*regexp.Regexp)(nil), time.Duration(0)).Run(record).Return(nil)
+
+			backupClient := &backup.MockClient{}
+			backupClient.On(backup.BackupGameMethod, mock.Anything, tt.game).Run(func(args mock.Arguments) {
+				calls = append(calls, backup.BackupGameMethod)
+			}).Return(nil)
+
+			b := newBackupScheduler(cfg, gameClient, backupClient, &discordbot.MockServer{})
+			gameCfg, _ := cfg.GetGameConfig(tt.game)
+			b.run(gameCfg)
+
+			assert.Equal(t, tt.expCalls, calls)
+		})
+	}
+}
+
+func Test_backupScheduler_update(t *testing.T) {
+	cfg := config.NewTestConfig(t, schedulerConfigFile)
+
+	// Record backups, checking those of different games never overlap
+	var mu sync.Mutex
+	active, overlapped := 0, false
+	backedUp := make(map[string]int)
+	backupClient := &backup.MockClient{}
+	backupClient.On(backup.BackupGameMethod, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		active++
+		overlapped = overlapped || active > 1
+		backedUp[args.String(1)]++
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}).Return(nil)
+	count := func(game string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return backedUp[game]
+	}
+
+	b := newBackupScheduler(cfg, &gameserver.MockClient{}, backupClient, &discordbot.MockServer{})
+	for _, game := range []string{"NoCommands", "AlsoNoCommands", "NotScheduled"} {
+		b.update(gameserver.Event{Game: game, From: gameserver.StateStarting, State: gameserver.StateRunning})
+	}
+
+	// Games are backed up while running
+	assert.Eventually(t, func() bool {
+		return count("NoCommands") >= 2 && count("AlsoNoCommands") >= 2
+	}, 5*time.Second, time.Millisecond)
+
+	// Backups end once the game stops
+	b.update(gameserver.Event{Game: "NoCommands", From: gameserver.StateRunning, State: gameserver.StateStopping})
+	time.Sleep(20 * time.Millisecond) // Allow any backup already started to finish
+	stopped := count("NoCommands")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, count("NoCommands"))
+	assert.Greater(t, count("AlsoNoCommands"), 2)
+	assert.Zero(t, count("NotScheduled"))
+
+	b.stop()
+	mu.Lock()
+	assert.False(t, overlapped)
+	mu.Unlock()
+}
+
+func Test_backupScheduler_stop(t *testing.T) {
+	cfg := config.NewTestConfig(t, schedulerConfigFile)
+
+	// Backup in progress is finished before stopping
+	started, finished := make(chan struct{}), make(chan struct{})
+	backupClient := &backup.MockClient{}
+	backupClient.On(backup.BackupGameMethod, mock.Anything, "NoCommands").Run(func(args mock.Arguments) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+	}).Return(nil).Once()
+
+	b := newBackupScheduler(cfg, &gameserver.MockClient{}, backupClient, &discordbot.MockServer{})
+	b.update(gameserver.Event{Game: "NoCommands", From: gameserver.StateStarting, State: gameserver.StateRunning})
+	<-started
+	b.stop()
+
+	select {
+	case <-finished:
+	default:
+		t.Fatal("scheduler stopped before backup finished")
+	}
+
+	// No more backups once stopped
+	b.update(gameserver.Event{Game: "NoCommands", From: gameserver.StateStarting, State: gameserver.StateRunning})
+	assert.Empty(t, b.schedules)
+	backupClient.AssertNumberOfCalls(t, backup.BackupGameMethod, 1)
+}
+
+func Test_backupScheduler_verify(t *testing.T) {
+	cfg := config.NewTestConfig(t, []byte(`{
+		"verify_interval": "10ms",
+		"games": [{"name": "Verified"}, {"name": "Corrupt"}, {"name": "NoBackup"}, {"name": "Legacy"}, {"name": "Incomplete"}]
+	}`))
+
+	backupClient := &backup.MockClient{}
+	backupClient.On(backup.VerifyMethod, mock.Anything, "Verified", time.Time{}).Return(nil)
+	backupClient.On(backup.VerifyMethod, mock.Anything, "Corrupt", time.Time{}).Return(backup.VerifyError{
+		Game:     "Corrupt",
+		Date:     "2024-01-02",
+		Failures: []string{"[world/level.dat] checksum mismatch"},
+	})
+	backupClient.On(backup.VerifyMethod, mock.Anything, "NoBackup", time.Time{}).Return(backup.NoBackupError{Game: "NoBackup"})
+	backupClient.On(backup.VerifyMethod, mock.Anything, "Legacy", time.Time{}).Return(backup.NoManifestError{Game: "Legacy", Date: "2024-01-02"})
+	backupClient.On(backup.VerifyMethod, mock.Anything, "Incomplete", time.Time{}).Return(fmt.Errorf("no complete backup of Incomplete found for 2024-01-02"))
+
+	// Only backups that failed or could not be verified are announced
+	announced := make(chan string, 16)
+	botServer := &discordbot.MockServer{}
+	botServer.On(discordbot.AnnounceMethod, mock.Anything).Run(func(args mock.Arguments) {
+		select {
+		case announced <- args.String(0):
+		default: // Repeated announcements are not needed
+		}
+	}).Return()
+
+	b := newBackupScheduler(cfg, &gameserver.MockClient{}, backupClient, botServer)
+	b.startVerify()
+
+	expMsgs := map[string]bool{
+		"The Corrupt backup from 2024-01-02 failed verification, 1 file(s) do not match":         true,
+		"The latest Incomplete backup could not be verified, it may be incomplete or unreadable": true,
+	}
+	for seen := make(map[string]bool); len(seen) < len(expMsgs); {
+		select {
+		case msg := <-announced:
+			assert.True(t, expMsgs[msg], msg)
+			seen[msg] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("failed verification was not announced")
+		}
+	}
+	b.stop()
+	b.stop()
+
+	// Backups without a manifest are never announced
+	botServer.AssertNotCalled(t, discordbot.AnnounceMethod, mock.MatchedBy(func(msg string) bool {
+		return strings.Contains(msg, "Legacy")
+	}))
+
+	// No more verification once stopped
+	verified := len(backupClient.Calls)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, verified, len(backupClient.Calls))
+}
+
+func Test_backupScheduler_cancel(t *testing.T) {
+	cfg := config.NewTestConfig(t, schedulerConfigFile)
+
+	// Backup in progress is cancelled rather than waited for
+	started := make(chan struct{})
+	backupClient := &backup.MockClient{}
+	backupClient.On(backup.BackupGameMethod, mock.Anything, "NoCommands").Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled).Once()
+
+	b := newBackupScheduler(cfg, &gameserver.MockClient{}, backupClient, &discordbot.MockServer{})
+	b.update(gameserver.Event{Game: "NoCommands", From: gameserver.StateStarting, State: gameserver.StateRunning})
+	<-started
+
+	cancelled := make(chan struct{})
+	go func() {
+		defer close(cancelled)
+		b.cancel()
+	}()
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler waited for backup in progress")
+	}
+	assert.Empty(t, b.schedules)
+	backupClient.AssertNumberOfCalls(t, backup.BackupGameMethod, 1)
+}
+
+func Test_backupScheduler_stop_Verify(t *testing.T) {
+	cfg := config.NewTestConfig(t, []byte(`{
+		"verify_interval": "10ms",
+		"games": [{"name": "Game"}]
+	}`))
+
+	// Verification in progress is abandoned rather than waited for
+	started := make(chan struct{})
+	backupClient := &backup.MockClient{}
+	backupClient.On(backup.VerifyMethod, mock.Anything, "Game", time.Time{}).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled).Once()
+
+	b := newBackupScheduler(cfg, &gameserver.MockClient{}, backupClient, &discordbot.MockServer{})
+	b.startVerify()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		b.stop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler waited for verification in progress")
+	}
+	backupClient.AssertNumberOfCalls(t, backup.VerifyMethod, 1)
+}
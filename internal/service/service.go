@@ -1,48 +1,111 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"game-server/internal/backup"
 	"game-server/internal/config"
 	discordbot "game-server/internal/discord/bot"
 	"game-server/internal/gameserver"
+	"game-server/pkg/aws/instance"
 	"game-server/pkg/monitor"
 )
 
-const inactivityThreshold = 15 * time.Minute
+const (
+	// Used when not set by the game or service config
+	defaultInactivityTimeout = 15 * time.Minute
+	defaultShutdownWarning   = 5 * time.Minute
+	defaultMaxExtension      = 2 * time.Hour
+
+	inactivityWarning   = "Server shutting down in %s due to inactivity"
+	interruptionWarning = "Server shutting down in %s, the host is being reclaimed"
+)
+
+var (
+	// ShutdownTimeout is the deadline for the teardown once a shutdown is requested by signal, as the process
+	// may be killed soon after, any steps remaining after it are abandoned besides stopping the instance
+	ShutdownTimeout = 5 * time.Minute
+
+	// EmergencyTimeout is the deadline for the teardown once the host is being reclaimed
+	EmergencyTimeout = 2 * time.Minute
+)
+
+// Signals which trigger a graceful shutdown
+var shutdownSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
 
 type Service struct {
 	cfg *config.Config
 
 	gameClient gameserver.ClientIFace
-	botServer  *discordbot.BotServer
+	botServer  discordbot.ServerIFace
 	monitor    monitor.ClientIFace
-	backup     *backup.Client
+	backup     backup.ClientIFace
+	instance   instance.ClientIFace
+	scheduler  *backupScheduler
 }
 
 func New() *Service {
 	cfg := config.New()
 	gameClient := gameserver.New(cfg)
+	backupClient := backup.New(cfg)
 
 	return &Service{
 		cfg: cfg,
 
 		gameClient: gameClient,
-		botServer:  discordbot.New(cfg, gameClient),
-		monitor:    monitor.New(inactivityThreshold),
-		backup:     backup.New(cfg),
+		botServer:  discordbot.New(cfg, gameClient, backupClient),
+		backup:     backupClient,
+		instance:   instance.New(),
 	}
 }
 
-func (s *Service) Run() {
+// SignalContext returns a context that is cancelled once the process receives a shutdown signal
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+	ctx, cancel := signalContext(parent, signals)
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// signalContext returns a context that is cancelled once a signal is received from the channel
+func signalContext(parent context.Context, signals <-chan os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Run serves until the game servers are inactive or the context is cancelled, then shuts down gracefully
+func (s *Service) Run(ctx context.Context) {
 	// Load game config
 	if err := s.cfg.Load(); err != nil {
 		s.cfg.Logger.Panic("failed to load config", zap.Error(err))
 	}
 
+	// Create activity monitor, which can be extended from the channel
+	if s.monitor == nil {
+		s.monitor = s.newMonitor()
+	}
+	s.botServer.SetMonitor(s.monitor)
+
 	// Start discord bot
 	go func() {
 		s.cfg.Logger.Info("starting discord bot")
@@ -55,39 +118,324 @@ func (s *Service) Run() {
 		s.cfg.Logger.Info("discord bot closed")
 	}()
 
+	// Follow game changes to keep the monitor armed and backups scheduled for the running games
+	events := s.gameClient.Subscribe()
+	s.scheduler = newBackupScheduler(s.cfg, s.gameClient, s.backup, s.botServer)
+	s.scheduler.startVerify()
+
+	// Watch for the host being reclaimed for as long as the service runs
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	defer cancelWatch()
+	interruption := s.watchInterruption(watchCtx)
+
 	// Start monitoring server activity
+	s.monitor.Rearm(s.monitorSettings(gameserver.Event{}))
 	inactive, err := s.monitor.Start(s.cfg.GetGamePorts())
 	if err != nil {
 		s.cfg.Logger.Panic("failed to monitor server activity", zap.Error(err))
 	}
 
 	// Await inactivity before triggering shutdown
-	<-inactive
-	s.cfg.Logger.Info("game server is inactive, initiating shutdown")
-	s.gracefulShutdown()
+	for {
+		select {
+		case <-inactive:
+			// Nothing is waiting on the service, so take as long as the backup needs
+			s.cfg.Logger.Info("game server is inactive, initiating shutdown")
+			s.gracefulShutdown(context.Background())
+			return
+
+		case <-ctx.Done():
+			s.cfg.Logger.Info("shutdown requested, initiating shutdown", zap.Error(ctx.Err()))
+			s.shutdown()
+			return
+
+		case notice := <-interruption:
+			s.cfg.Logger.Warn("host is being reclaimed, initiating emergency shutdown", zap.String("action", notice.Action), zap.Time("time", notice.Time))
+			s.emergencyShutdown(notice)
+			return
+
+		case remaining := <-s.monitor.Warning():
+			s.warnInactive(remaining)
+			s.botServer.ShutdownPending(remaining)
+
+		case e := <-events:
+			// Rearm when a game starts or stops, rather than on every state change
+			if e.State != e.From && (e.State == gameserver.StateStarting || e.State == gameserver.StateStopped) {
+				s.monitor.Rearm(s.monitorSettings(e))
+			}
+			s.scheduler.update(e)
+		}
+	}
 }
 
-func (s *Service) gracefulShutdown() {
+// shutdown runs the graceful shutdown within the shutdown deadline, for when the process may soon be killed
+func (s *Service) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	s.gracefulShutdown(ctx)
+}
+
+// step is a single part of the teardown
+type step struct {
+	name   string
+	run    func()
+	always bool // Run even once the deadline is exceeded
+}
+
+// gracefulShutdown tears down the service in order, abandoning any remaining steps once the context is done
+func (s *Service) gracefulShutdown(ctx context.Context) {
+	s.teardown(ctx, s.shutdownSteps())
+}
+
+// emergencyShutdown warns of the host being reclaimed and backs up straight away, as there may not be time
+// to stop the games first, then tears down the service with whatever time remains
+func (s *Service) emergencyShutdown(notice instance.InterruptionNotice) {
+	deadline := time.Now().Add(EmergencyTimeout)
+	if !notice.Time.IsZero() && notice.Time.Before(deadline) {
+		deadline = notice.Time
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	steps := []step{
+		{name: "warn players", run: func() {
+			msg := fmt.Sprintf(interruptionWarning, time.Until(deadline).Round(time.Second))
+			s.warnPlayers(msg)
+			s.botServer.Announce(msg)
+		}},
+		{name: "cancel backup scheduler", run: s.cancelScheduler},
+
+		// Only running games have saves that may not be backed up yet
+		{name: "emergency backup", run: func() {
+			if err := s.backup.ForceBackup(s.gameClient.Running()...); err != nil {
+				s.cfg.Logger.Error("error encountered backing up save data", zap.Error(err))
+			}
+		}},
+	}
+	s.teardown(ctx, append(steps, s.shutdownSteps()...))
+}
+
+// shutdownSteps gets the ordered steps of a graceful shutdown
+func (s *Service) shutdownSteps() []step {
+	return []step{
+		// Finish any backup of a running game before it is stopped
+		{name: "stop backup scheduler", run: s.stopScheduler},
+
+		// Shutdown all running game servers, warning their players first
+		{name: "stop game servers", run: func() {
+			var wg sync.WaitGroup
+			for _, game := range s.gameClient.Running() {
+				wg.Add(1)
+				go func(game string) {
+					defer wg.Done()
+					if err := s.gameClient.Stop(game); err != nil {
+						s.cfg.Logger.Error("could not shutdown game server", zap.Error(err), zap.String("game", game))
+					}
+				}(game)
+			}
+			wg.Wait()
+		}},
+
+		// Stop monitoring server activity
+		{name: "close monitor", run: s.monitor.Close},
+
+		// Stop Discord bot server
+		{name: "stop discord bot", run: func() {
+			if err := s.botServer.Stop(); err != nil {
+				s.cfg.Logger.Error("could not shutdown discord bot", zap.Error(err))
+			}
+		}},
+
+		// Backup game save data
+		{name: "backup", run: func() {
+			if err := s.backup.DoBackup(); err != nil {
+				s.cfg.Logger.Error("error encountered backing up save data", zap.Error(err))
+			}
+		}},
+
+		// Stop the host instance now that everything is saved, or as much as could be in time,
+		// so it is never left running
+		{name: "stop instance", always: true, run: func() {
+			if !s.cfg.Settings.StopInstance {
+				return
+			}
+			if err := s.stopInstance(); err != nil {
+				s.cfg.Logger.Error("could not stop instance", zap.Error(err))
+			}
+		}},
+	}
+}
+
+// teardown runs each step in order, abandoning any remaining steps once the context is done
+// other than those that always run
+func (s *Service) teardown(ctx context.Context, steps []step) {
 	// Flushes log buffer, if any
 	defer s.cfg.Logger.Sync()
 
-	// Shutdown game server if currently running
-	if _, running := s.gameClient.IsRunning(); running {
-		if err := s.gameClient.Stop(); err != nil {
-			s.cfg.Logger.Error("could not shutdown game server", zap.Error(err))
+	abandoned := false
+	for _, step := range steps {
+		if abandoned {
+			if step.always {
+				step.run()
+			}
+			continue
+		}
+
+		done := make(chan struct{})
+		go func(run func()) {
+			defer close(done)
+			run()
+		}(step.run)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			s.cfg.Logger.Error("shutdown deadline exceeded, abandoning teardown", zap.String("step", step.name), zap.Error(ctx.Err()))
+			abandoned = true
 		}
 	}
+}
+
+// stopScheduler ends backups of running games, if scheduled
+func (s *Service) stopScheduler() {
+	if s.scheduler != nil {
+		s.scheduler.stop()
+	}
+}
+
+// cancelScheduler ends backups of running games, abandoning any in progress so there is time for an emergency backup
+func (s *Service) cancelScheduler() {
+	if s.scheduler != nil {
+		s.scheduler.cancel()
+	}
+}
+
+// stopInstance stops the instance the service is running on, the service is halted as it stops
+func (s *Service) stopInstance() error {
+	if err := s.instance.Connect(); err != nil {
+		return err
+	}
+
+	id, err := s.instance.GetSelfId()
+	if err != nil {
+		return err
+	}
+
+	s.cfg.Logger.Info("stopping instance", zap.String("instanceId", id))
+	return s.instance.StopInstance(id)
+}
+
+// monitorSettings gets the inactivity settings for the running games, using the longest timeout and warning of any
+// of them, along with the grace period of a game that is starting
+func (s *Service) monitorSettings(e gameserver.Event) monitor.Settings {
+	settings := monitor.Settings{
+		Timeout:      defaultInactivityTimeout,
+		MaxExtension: defaultMaxExtension,
+	}
+	if maxExtension := s.cfg.Settings.MaxExtension.Duration; maxExtension > 0 {
+		settings.MaxExtension = maxExtension
+	}
+
+	var timeout, warning time.Duration
+	for _, game := range s.gameClient.Running() {
+		gameCfg, ok := s.cfg.GetGameConfig(game)
+		if !ok {
+			continue
+		}
+
+		gameTimeout := gameCfg.InactivityTimeout.Duration
+		if gameTimeout == 0 {
+			gameTimeout = defaultInactivityTimeout
+		}
+		if gameTimeout > timeout {
+			timeout = gameTimeout
+		}
+		if gameWarning := gameCfg.WarningBeforeShutdown.Duration; gameWarning > warning {
+			warning = gameWarning
+		}
+	}
+	if timeout > 0 {
+		settings.Timeout = timeout
+	}
+
+	// Games' own warnings take priority over the service warning
+	settings.Warning = warning
+	if warning == 0 {
+		settings.Warning = defaultShutdownWarning
+		if serviceWarning := s.cfg.Settings.ShutdownWarning.Duration; serviceWarning > 0 {
+			settings.Warning = serviceWarning
+		}
+	}
+
+	if e.State == gameserver.StateStarting {
+		if gameCfg, ok := s.cfg.GetGameConfig(e.Game); ok {
+			settings.Grace = gameCfg.StartupGrace.Duration
+		}
+	}
+	return settings
+}
 
-	// Stop monitoring server activity
-	s.monitor.Close()
+// warnInactive warns the players of each running game of the pending shutdown
+func (s *Service) warnInactive(remaining time.Duration) {
+	s.warnPlayers(fmt.Sprintf(inactivityWarning, remaining.Round(time.Second)))
+}
 
-	// Stop Discord bot server
-	if err := s.botServer.Stop(); err != nil {
-		s.cfg.Logger.Error("could not shutdown discord bot", zap.Error(err))
+// warnPlayers sends a message to the players of each running game
+func (s *Service) warnPlayers(msg string) {
+	for _, game := range s.gameClient.Running() {
+		if err := s.gameClient.Message(game, msg); err != nil {
+			s.cfg.Logger.Error("could not send warning", zap.Error(err), zap.String("game", game))
+		}
 	}
+}
 
-	// Backup game save data
-	if err := s.backup.DoBackup(); err != nil {
-		s.cfg.Logger.Error("error encountered backing up save data", zap.Error(err))
+// watchInterruption watches for the host spot instance being reclaimed, if enabled by the config settings
+func (s *Service) watchInterruption(ctx context.Context) <-chan instance.InterruptionNotice {
+	if !s.cfg.Settings.SpotInterruption {
+		return nil
+	}
+	if err := s.instance.Connect(); err != nil {
+		s.cfg.Logger.Error("could not watch for spot interruption", zap.Error(err))
+		return nil
+	}
+	return s.instance.WatchInterruption(ctx)
+}
+
+// newMonitor gets the inactivity monitor chosen by the config settings
+func (s *Service) newMonitor() monitor.ClientIFace {
+	switch s.cfg.Settings.InactivityMonitor {
+	case config.MonitorPlayers:
+		return monitor.NewPlayers(defaultInactivityTimeout, &playerCounter{logger: s.cfg.Logger, gameClient: s.gameClient})
+	case config.MonitorComposite:
+		return monitor.NewComposite(defaultInactivityTimeout,
+			monitor.New(defaultInactivityTimeout),
+			monitor.NewPlayers(defaultInactivityTimeout, &playerCounter{logger: s.cfg.Logger, gameClient: s.gameClient}),
+		)
+	}
+	return monitor.New(defaultInactivityTimeout)
+}
+
+// playerCounter counts the players across all running games
+type playerCounter struct {
+	logger     *zap.Logger
+	gameClient gameserver.ClientIFace
+}
+
+// PlayerCount counts the players of every game that could be queried, with an error for any that could not
+func (p *playerCounter) PlayerCount() (int, error) {
+	count := 0
+	var errs error
+	for _, game := range p.gameClient.Running() {
+		status, err := p.gameClient.Status(game)
+		if errors.Is(err, gameserver.ErrNoQuery) {
+			// Games without a query protocol can only be monitored by their packets
+			continue
+		} else if err != nil {
+			p.logger.Warn("could not count players", zap.String("game", game), zap.Error(err))
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		count += status.Players
 	}
+	return count, errs
 }
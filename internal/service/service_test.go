@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"game-server/internal/backup"
+	"game-server/internal/config"
+	discordbot "game-server/internal/discord/bot"
+	"game-server/internal/gameserver"
+	"game-server/pkg/aws/instance"
+	"game-server/pkg/monitor"
+	"game-server/pkg/query"
+)
+
+var testConfigFile = []byte(`[
+	{
+		"name": "Quick",
+		"inactivity_timeout": "5m",
+		"startup_grace": "1m",
+		"warning_before_shutdown": "1m"
+	},
+	{
+		"name": "Slow",
+		"inactivity_timeout": "30m",
+		"startup_grace": "10m",
+		"warning_before_shutdown": "5m"
+	},
+	{
+		"name": "Default"
+	}
+]`)
+
+func Test_Service_monitorSettings(t *testing.T) {
+	cfg := config.NewTestConfig(t, testConfigFile)
+
+	tests := []struct {
+		name        string
+		event       gameserver.Event
+		running     []string
+		settings    config.Settings
+		expSettings monitor.Settings
+	}{
+		{
+			name:        "Happy path - No games running",
+			event:       gameserver.Event{Game: "Quick", From: gameserver.StateStopping, State: gameserver.StateStopped},
+			expSettings: monitor.Settings{Timeout: defaultInactivityTimeout, Warning: defaultShutdownWarning, MaxExtension: defaultMaxExtension},
+		},
+		{
+			name:  "Happy path - Service settings",
+			event: gameserver.Event{Game: "Quick", From: gameserver.StateStopping, State: gameserver.StateStopped},
+			settings: config.Settings{
+				ShutdownWarning: config.Duration{Duration: 2 * time.Minute},
+				MaxExtension:    config.Duration{Duration: 3 * time.Hour},
+			},
+			expSettings: monitor.Settings{Timeout: defaultInactivityTimeout, Warning: 2 * time.Minute, MaxExtension: 3 * time.Hour},
+		},
+		{
+			name:        "Happy path - Game starting",
+			event:       gameserver.Event{Game: "Quick", From: gameserver.StateStopped, State: gameserver.StateStarting},
+			running:     []string{"Quick"},
+			expSettings: monitor.Settings{Timeout: 5 * time.Minute, Grace: time.Minute, Warning: time.Minute, MaxExtension: defaultMaxExtension},
+		},
+		{
+			name:        "Happy path - Longest settings of running games",
+			event:       gameserver.Event{Game: "Quick", From: gameserver.StateStopped, State: gameserver.StateStarting},
+			running:     []string{"Quick", "Slow"},
+			expSettings: monitor.Settings{Timeout: 30 * time.Minute, Grace: time.Minute, Warning: 5 * time.Minute, MaxExtension: defaultMaxExtension},
+		},
+		{
+			name:        "Happy path - Game without settings",
+			event:       gameserver.Event{Game: "Slow", From: gameserver.StateStopping, State: gameserver.StateStopped},
+			running:     []string{"Quick", "Default"},
+			expSettings: monitor.Settings{Timeout: defaultInactivityTimeout, Warning: time.Minute, MaxExtension: defaultMaxExtension},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGameClient := new(gameserver.MockClient)
+			mockGameClient.On(gameserver.RunningMethod).Return(tt.running)
+			cfg.Settings = tt.settings
+
+			s := &Service{
+				cfg:        cfg,
+				gameClient: mockGameClient,
+			}
+
+			assert.Equal(t, tt.expSettings, s.monitorSettings(tt.event))
+		})
+	}
+}
+
+func Test_Service_gracefulShutdown(t *testing.T) {
+	const instanceId = "i-0123456789abcdef0"
+
+	tests := []struct {
+		name         string
+		stopInstance bool
+		selfIdErr    error
+		stopDelay    time.Duration
+		expBackup    bool
+		expStopped   bool
+	}{
+		{
+			name:      "Happy path - Instance left running",
+			expBackup: true,
+		},
+		{
+			name:         "Happy path - Instance stopped",
+			stopInstance: true,
+			expBackup:    true,
+			expStopped:   true,
+		},
+		{
+			name:         "Sad path - Cannot identify instance",
+			stopInstance: true,
+			selfIdErr:    errors.New("metadata unavailable"),
+			expBackup:    true,
+		},
+		{
+			name:         "Sad path - Deadline exceeded",
+			stopInstance: true,
+			stopDelay:    time.Second,
+			expStopped:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewTestConfig(t, testConfigFile)
+			cfg.Settings.StopInstance = tt.stopInstance
+
+			gameClient := &gameserver.MockClient{}
+			gameClient.On(gameserver.RunningMethod).Return([]string{"Quick"})
+			gameClient.On(gameserver.StopMethod, "Quick").After(tt.stopDelay).Return(nil)
+
+			monitorClient := &monitor.MockClient{}
+			monitorClient.On(monitor.CloseMethod).Return()
+
+			botServer := &discordbot.MockServer{}
+			botServer.On(discordbot.StopMethod).Return(nil)
+
+			backupClient := &backup.MockClient{}
+			backupClient.On(backup.DoBackupMethod).Return(nil)
+
+			instanceClient := &instance.MockClient{}
+			instanceClient.On(instance.ConnectMethod).Return(nil)
+			instanceClient.On(instance.GetSelfIdMethod).Return(instanceId, tt.selfIdErr)
+			instanceClient.On(instance.StopInstanceMethod, instanceId).Return(nil)
+
+			s := &Service{
+				cfg:        cfg,
+				gameClient: gameClient,
+				botServer:  botServer,
+				monitor:    monitorClient,
+				backup:     backupClient,
+				instance:   instanceClient,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			s.gracefulShutdown(ctx)
+
+			gameClient.AssertCalled(t, gameserver.StopMethod, "Quick")
+			if tt.expBackup {
+				backupClient.AssertCalled(t, backup.DoBackupMethod)
+			} else {
+				backupClient.AssertNotCalled(t, backup.DoBackupMethod)
+			}
+			if tt.expStopped {
+				instanceClient.AssertCalled(t, instance.StopInstanceMethod, instanceId)
+			} else {
+				instanceClient.AssertNotCalled(t, instance.StopInstanceMethod, instanceId)
+			}
+			if !tt.stopInstance {
+				instanceClient.AssertNotCalled(t, instance.ConnectMethod)
+			}
+		})
+	}
+}
+
+func Test_Service_Run(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"spot_interruption": true, "games": [{"name": "Quick"}]}`), 0o644))
+	t.Setenv(config.EnvGameConfig, configPath)
+
+	tests := []struct {
+		name         string
+		signal       bool
+		notice       bool
+		expEmergency bool
+	}{
+		{
+			name:   "Happy path - Shutdown signal",
+			signal: true,
+		},
+		{
+			name:         "Happy path - Spot interruption",
+			notice:       true,
+			expEmergency: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Logger = config.NewTestLogger()
+
+			events := make(chan gameserver.Event)
+			gameClient := &gameserver.MockClient{}
+			gameClient.On(gameserver.SubscribeMethod).Return((<-chan gameserver.Event)(events))
+			gameClient.On(gameserver.RunningMethod).Return([]string{"Quick"})
+			gameClient.On(gameserver.MessageMethod, "Quick", mock.Anything).Return(nil)
+			gameClient.On(gameserver.StopMethod, "Quick").Return(nil)
+
+			monitorClient := &monitor.MockClient{}
+			monitorClient.On(monitor.RearmMethod, mock.Anything).Return()
+			monitorClient.On(monitor.StartMethod, mock.Anything).Return(make(chan struct{}), nil)
+			monitorClient.On(monitor.WarningMethod).Return(make(chan time.Duration))
+			monitorClient.On(monitor.CloseMethod).Return()
+
+			botServer := &discordbot.MockServer{}
+			botServer.On(discordbot.SetMonitorMethod, monitorClient).Return()
+			botServer.On(discordbot.ConnectMethod).Return(nil)
+			botServer.On(discordbot.RunMethod).Return(nil)
+			botServer.On(discordbot.StopMethod).Return(nil)
+			botServer.On(discordbot.AnnounceMethod, mock.Anything).Return()
+
+			backupClient := &backup.MockClient{}
+			backupClient.On(backup.DoBackupMethod).Return(nil)
+			backupClient.On(backup.ForceBackupMethod, []string{"Quick"}).Return(nil)
+
+			notices := make(chan instance.InterruptionNotice, 1)
+			instanceClient := &instance.MockClient{}
+			instanceClient.On(instance.ConnectMethod).Return(nil)
+			instanceClient.On(instance.WatchInterruptionMethod, mock.Anything).Return((<-chan instance.InterruptionNotice)(notices))
+
+			s := &Service{
+				cfg:        cfg,
+				gameClient: gameClient,
+				botServer:  botServer,
+				monitor:    monitorClient,
+				backup:     backupClient,
+				instance:   instanceClient,
+			}
+
+			signals := make(chan os.Signal, 1)
+			ctx, stop := signalContext(context.Background(), signals)
+			defer stop()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.Run(ctx)
+			}()
+
+			if tt.signal {
+				signals <- syscall.SIGTERM
+			}
+			if tt.notice {
+				notices <- instance.InterruptionNotice{Action: "terminate", Time: time.Now().Add(time.Minute)}
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "service did not shut down")
+			}
+			gameClient.AssertCalled(t, gameserver.StopMethod, "Quick")
+			monitorClient.AssertCalled(t, monitor.CloseMethod)
+			botServer.AssertCalled(t, discordbot.StopMethod)
+			backupClient.AssertCalled(t, backup.DoBackupMethod)
+			if tt.expEmergency {
+				gameClient.AssertCalled(t, gameserver.MessageMethod, "Quick", mock.MatchedBy(func(msg string) bool {
+					return strings.HasSuffix(msg, "the host is being reclaimed")
+				}))
+				botServer.AssertCalled(t, discordbot.AnnounceMethod, mock.Anything)
+				backupClient.AssertCalled(t, backup.ForceBackupMethod, []string{"Quick"})
+			} else {
+				backupClient.AssertNotCalled(t, backup.ForceBackupMethod)
+			}
+		})
+	}
+}
+
+func Test_playerCounter_PlayerCount(t *testing.T) {
+	gameClient := &gameserver.MockClient{}
+	gameClient.On(gameserver.RunningMethod).Return([]string{"Queried", "NotQueried", "Failed"})
+	gameClient.On(gameserver.StatusMethod, "Queried").Return(&query.Status{Players: 2}, nil)
+	gameClient.On(gameserver.StatusMethod, "NotQueried").Return(nil, gameserver.ErrNoQuery)
+	gameClient.On(gameserver.StatusMethod, "Failed").Return(nil, errors.New("mock error"))
+
+	p := &playerCounter{logger: config.NewTestLogger(), gameClient: gameClient}
+	count, err := p.PlayerCount()
+
+	// Players of the games that answered are still counted
+	assert.Equal(t, 2, count)
+	assert.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +26,25 @@ func main() {
 				fmt.Println(mockserver.ShutdownResponse)
 				return
 
+			case strings.HasPrefix(line, mockserver.SpamCommand):
+				count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, mockserver.SpamCommand)))
+				if err != nil {
+					fmt.Printf("Invalid spam count: [%s]\n", line)
+					continue
+				}
+				for i := 0; i < count; i++ {
+					fmt.Printf("Spam line %d\n", i)
+					fmt.Fprintf(os.Stderr, "Spam error line %d\n", i)
+				}
+
+			case strings.HasPrefix(line, mockserver.CrashCommand):
+				code, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, mockserver.CrashCommand)))
+				if err != nil {
+					fmt.Printf("Invalid exit code: [%s]\n", line)
+					continue
+				}
+				os.Exit(code)
+
 			case strings.HasPrefix(line, mockserver.MessageCommand):
 				msg := strings.TrimPrefix(line, mockserver.MessageCommand)
 				msg = strings.TrimPrefix(msg, " ")
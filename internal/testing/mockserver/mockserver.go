@@ -2,7 +2,11 @@ package mockserver
 
 import (
 	_ "embed"
-	"path"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -20,6 +24,10 @@ const (
 	MessageCommand = "/message"
 	StopCommand    = "/stop"
 
+	// Mock server only commands
+	SpamCommand  = "/spam"  // Prints the given number of lines to stdout and stderr
+	CrashCommand = "/crash" // Exits immediately with the given code
+
 	// Mock server messages
 	StartupMessage   = "Started mock server"
 	ShutdownResponse = "Got shutdown command"
@@ -34,15 +42,70 @@ var SaveFilePaths = []string{
 
 func GetConfig(t *testing.T) *config.Config {
 	cfg := config.NewTestConfig(t, configFile)
+	setWorkingDir(t, cfg)
+	return cfg
+}
+
+// GetMultiGameConfig gets a config with a copy of the mock game under each of the given names
+func GetMultiGameConfig(t *testing.T, names ...string) *config.Config {
+	var games []map[string]interface{}
+	require.NoError(t, json.Unmarshal(configFile, &games), "Could not parse mock config")
+
+	multiGames := make([]map[string]interface{}, len(names))
+	for i, name := range names {
+		multiGames[i] = make(map[string]interface{}, len(games[0]))
+		for key, val := range games[0] {
+			multiGames[i][key] = val
+		}
+		multiGames[i]["name"] = name
+	}
+	multiConfigFile, err := json.Marshal(multiGames)
+	require.NoError(t, err, "Could not build multiple game config")
+
+	cfg := config.NewTestConfig(t, multiConfigFile)
+	setWorkingDir(t, cfg)
+	return cfg
+}
 
-	// Set working directory
+// setWorkingDir gives the games a working directory of their own, with a copy of the mock save data
+// and the mock server built to run in it, so tests never write to the source tree
+func setWorkingDir(t *testing.T, cfg *config.Config) {
 	_, filePath, _, ok := runtime.Caller(0)
-	require.True(t, ok, "No caller information getting mock server working directory")
-	workingDir := path.Dir(filePath)
+	require.True(t, ok, "No caller information getting mock server source directory")
+	srcDir := filepath.Dir(filePath)
+	workingDir := t.TempDir()
+
+	// Copy the save data
+	err := filepath.WalkDir(filepath.Join(srcDir, "savedata"), func(srcPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, srcPath)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(workingDir, relPath), 0o755)
+		}
+		data, err := os.ReadFile(srcPath)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(workingDir, relPath), data, 0o644)
+	})
+	require.NoError(t, err, "Could not copy mock save data")
+
+	// Build the mock server, as it cannot be run with go run outside of the module
+	serverPath := filepath.Join(workingDir, "mockserver")
+	build := exec.Command("go", "build", "-o", serverPath, "./cmd")
+	build.Dir = srcDir
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, "Could not build mock server: %s", out)
+
 	for _, game := range cfg.GetGameNames() {
 		gameCfg, _ := cfg.GetGameConfig(game)
 		gameCfg.WorkingDir = workingDir
+		gameCfg.Run.Command = serverPath
+		gameCfg.Run.Args = nil
 	}
-
-	return cfg
 }
@@ -1,9 +1,14 @@
 package instance
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
@@ -16,8 +21,16 @@ const (
 	InstanceStoppedState      = "stopped"
 	InstanceShuttingDownState = "shutting-down"
 	InstanceTerminatedState   = "terminated"
+
+	// Overrides the instance metadata service endpoint, such as with a local fake
+	EnvMetadataEndpoint = "INSTANCE_METADATA_ENDPOINT"
+
+	instanceIdMetadata = "instance-id"
 )
 
+// StopPollRate is how often the instance state is checked while waiting for it to stop
+var StopPollRate = 5 * time.Second
+
 // Ensure Client implements ClientIFace
 var _ ClientIFace = (*Client)(nil)
 
@@ -28,6 +41,10 @@ type ClientIFace interface {
 	GetInstanceState(id string) (state string, err error)
 	GetInstanceAddress(id string) (address string, err error)
 	StartInstance(id string) error
+	StopInstance(id string) error
+	WaitUntilStopped(ctx context.Context, id string) error
+	GetSelfId() (string, error)
+	WatchInterruption(ctx context.Context) <-chan InterruptionNotice
 }
 
 type Client struct {
@@ -108,3 +125,40 @@ func (c *Client) StartInstance(id string) error {
 	_, err := c.instanceClient.StartInstances(in)
 	return err
 }
+
+func (c *Client) StopInstance(id string) error {
+	in := &ec2.StopInstancesInput{
+		InstanceIds: []*string{
+			aws.String(id),
+		},
+	}
+
+	_, err := c.instanceClient.StopInstances(in)
+	return err
+}
+
+// WaitUntilStopped waits for the instance to reach the stopped state, failing if it is terminated instead
+func (c *Client) WaitUntilStopped(ctx context.Context, id string) error {
+	in := &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{
+			aws.String(id),
+		},
+	}
+
+	return c.instanceClient.WaitUntilInstanceStoppedWithContext(ctx, in, request.WithWaiterDelay(request.ConstantWaiterDelay(StopPollRate)))
+}
+
+// GetSelfId gets the ID of the instance this is running on from the instance metadata service
+func (c *Client) GetSelfId() (string, error) {
+	return c.metadata().GetMetadata(instanceIdMetadata)
+}
+
+func (c *Client) metadata() *ec2metadata.EC2Metadata {
+	cfg := aws.NewConfig()
+	if endpoint := os.Getenv(EnvMetadataEndpoint); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint)
+	}
+
+	// Session tokens for IMDSv2 are handled by the metadata client
+	return ec2metadata.New(c.session, cfg)
+}
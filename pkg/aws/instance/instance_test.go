@@ -0,0 +1,172 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInstanceId = "i-0123456789abcdef0"
+
+// fakeEC2 records the instances it is asked to stop
+type fakeEC2 struct {
+	ec2iface.EC2API
+
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (f *fakeEC2) StopInstances(in *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.InstanceIds {
+		f.stopped = append(f.stopped, *id)
+	}
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func Test_Client_StopInstance(t *testing.T) {
+	fake := &fakeEC2{}
+	c := &Client{instanceClient: fake}
+
+	require.NoError(t, c.StopInstance(testInstanceId))
+
+	assert.Equal(t, []string{testInstanceId}, fake.stopped)
+}
+
+func Test_Client_WaitUntilStopped(t *testing.T) {
+	// Speed up polling
+	defer func(origRate time.Duration) {
+		StopPollRate = origRate
+	}(StopPollRate)
+	StopPollRate = time.Millisecond
+
+	tests := []struct {
+		name    string
+		states  []string
+		timeout time.Duration
+		expErr  bool
+	}{
+		{
+			name:   "Happy path - Already stopped",
+			states: []string{InstanceStoppedState},
+		},
+		{
+			name:   "Happy path - Stops",
+			states: []string{InstanceRunningState, InstanceStoppingState, InstanceStoppingState, InstanceStoppedState},
+		},
+		{
+			name:   "Sad path - Terminated",
+			states: []string{InstanceStoppingState, InstanceTerminatedState},
+			expErr: true,
+		},
+		{
+			name:    "Sad path - Context done",
+			states:  []string{InstanceStoppingState},
+			timeout: 50 * time.Millisecond,
+			expErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fake EC2 API reporting each of the states in turn, holding on the last
+			var mu sync.Mutex
+			states := tt.states
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				state := states[0]
+				if len(states) > 1 {
+					states = states[1:]
+				}
+				mu.Unlock()
+
+				w.Header().Set("Content-Type", "text/xml")
+				fmt.Fprintf(w, describeInstancesResponse, testInstanceId, state)
+			}))
+			defer srv.Close()
+
+			awsSession, err := session.NewSession(aws.NewConfig().
+				WithRegion("us-east-1").
+				WithEndpoint(srv.URL).
+				WithCredentials(credentials.AnonymousCredentials))
+			require.NoError(t, err)
+			c := New()
+			c.ConnectWithSession(awsSession)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			err = c.WaitUntilStopped(ctx, testInstanceId)
+
+			if tt.expErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+const describeInstancesResponse = `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <reservationSet>
+    <item>
+      <instancesSet>
+        <item>
+          <instanceId>%s</instanceId>
+          <instanceState><name>%s</name></instanceState>
+        </item>
+      </instancesSet>
+    </item>
+  </reservationSet>
+</DescribeInstancesResponse>`
+
+func Test_Client_GetSelfId(t *testing.T) {
+	token := "metadata-token"
+
+	// Fake metadata service requiring an IMDSv2 session token
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			w.Write([]byte(token))
+		case r.Method == http.MethodGet && r.URL.Path == "/latest/meta-data/instance-id":
+			if r.Header.Get("X-aws-ec2-metadata-token") != token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(testInstanceId))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv(EnvMetadataEndpoint, srv.URL)
+
+	awsSession, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.AnonymousCredentials))
+	require.NoError(t, err)
+	c := New()
+	c.ConnectWithSession(awsSession)
+
+	id, err := c.GetSelfId()
+
+	require.NoError(t, err)
+	assert.Equal(t, testInstanceId, id)
+}
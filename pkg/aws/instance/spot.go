@@ -0,0 +1,47 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const spotInstanceActionMetadata = "spot/instance-action"
+
+// Spot interruption notices are given two minutes ahead, so poll well within that
+var SpotPollRate = 5 * time.Second
+
+// InterruptionNotice is the action to be taken on a spot instance, and when it will be taken
+type InterruptionNotice struct {
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+}
+
+// WatchInterruption polls the instance metadata for a spot interruption notice until the context is done,
+// the returned channel receives at most one notice
+func (c *Client) WatchInterruption(ctx context.Context) <-chan InterruptionNotice {
+	notices := make(chan InterruptionNotice, 1)
+	metadata := c.metadata()
+	pollRate := SpotPollRate
+
+	go func() {
+		for {
+			// Metadata is missing until an interruption is scheduled, so any failure is retried
+			if data, err := metadata.GetMetadataWithContext(ctx, spotInstanceActionMetadata); err == nil {
+				var notice InterruptionNotice
+				if err := json.Unmarshal([]byte(data), &notice); err == nil {
+					notices <- notice
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollRate):
+			}
+		}
+	}()
+
+	return notices
+}
@@ -0,0 +1,81 @@
+package instance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client_WatchInterruption(t *testing.T) {
+	// Speed up polling
+	defer func(orig time.Duration) { SpotPollRate = orig }(SpotPollRate)
+	SpotPollRate = time.Millisecond
+
+	tests := []struct {
+		name      string
+		noticeAt  int32 // Poll at which the notice appears, zero for never
+		expNotice bool
+	}{
+		{
+			name:      "Happy path - Notice given",
+			noticeAt:  3,
+			expNotice: true,
+		},
+		{
+			name: "Happy path - No notice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fake metadata service, missing the instance action until the notice is given
+			var polls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+					w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+					w.Write([]byte("metadata-token"))
+				case r.Method == http.MethodGet && r.URL.Path == "/latest/meta-data/spot/instance-action":
+					if poll := atomic.AddInt32(&polls, 1); tt.noticeAt == 0 || poll < tt.noticeAt {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Write([]byte(`{"action": "terminate", "time": "2017-09-18T08:22:00Z"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+			t.Setenv(EnvMetadataEndpoint, srv.URL)
+
+			awsSession, err := session.NewSession(aws.NewConfig().
+				WithRegion("us-east-1").
+				WithCredentials(credentials.AnonymousCredentials))
+			require.NoError(t, err)
+			c := New()
+			c.ConnectWithSession(awsSession)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			select {
+			case notice := <-c.WatchInterruption(ctx):
+				require.True(t, tt.expNotice, "unexpected notice")
+				assert.Equal(t, "terminate", notice.Action)
+				assert.Equal(t, time.Date(2017, 9, 18, 8, 22, 0, 0, time.UTC), notice.Time)
+			case <-ctx.Done():
+				require.False(t, tt.expNotice, "no notice given")
+				assert.Greater(t, atomic.LoadInt32(&polls), int32(1))
+			}
+		})
+	}
+}
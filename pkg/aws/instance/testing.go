@@ -1,6 +1,8 @@
 package instance
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/stretchr/testify/mock"
 )
@@ -12,6 +14,10 @@ const (
 	GetInstanceStateMethod   = "GetInstanceState"
 	GetInstanceAddressMethod = "GetInstanceAddress"
 	StartInstanceMethod      = "StartInstance"
+	StopInstanceMethod       = "StopInstance"
+	WaitUntilStoppedMethod   = "WaitUntilStopped"
+	GetSelfIdMethod          = "GetSelfId"
+	WatchInterruptionMethod  = "WatchInterruption"
 )
 
 // Ensure MockClient implements ClientIFace
@@ -49,3 +55,23 @@ func (m *MockClient) StartInstance(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *MockClient) StopInstance(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockClient) WaitUntilStopped(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockClient) GetSelfId() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockClient) WatchInterruption(ctx context.Context) <-chan InterruptionNotice {
+	args := m.Called(ctx)
+	return args.Get(0).(<-chan InterruptionNotice)
+}
@@ -1,18 +1,25 @@
 package s3
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 const (
 	Delimiter = "/"
+
+	// Most keys that can be deleted in one request
+	maxDeleteKeys = 1000
 )
 
 // Ensure Client implements ClientIFace
@@ -24,6 +31,20 @@ type ClientIFace interface {
 	GetSession() *session.Session
 	GetFolders(bucket string, depth int) ([]string, error)
 	Put(file io.ReadSeeker, bucket string, key string) error
+	Upload(body io.Reader, bucket string, key string) error
+	List(bucket string, prefix string) ([]string, error)
+	ListObjects(bucket string, prefix string, fn func(obj Object) error) error
+	Get(bucket string, key string) (io.ReadCloser, error)
+	Checksum(bucket string, key string) (string, error)
+	DeleteObjects(bucket string, keys []string) error
+}
+
+// Object describes a stored file
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string // Without quotes, the MD5 of the file only if uploaded in one part
+	LastModified time.Time
 }
 
 type Client struct {
@@ -57,50 +78,171 @@ func (c *Client) GetSession() *session.Session {
 	return c.session
 }
 
+// GetFolders gets every folder in the bucket up to the given depth, each ending with delimiter,
+// where folders are the prefixes of keys rather than any trailing-delimiter keys
 func (c *Client) GetFolders(bucket string, depth int) ([]string, error) {
 	if depth < 1 {
 		return nil, fmt.Errorf("subdirectory depth must be at least 1")
 	}
 
-	req := &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
-	}
-	out, err := c.s3Client.ListObjectsV2(req)
-	if err != nil {
-		return nil, err
+	// List each level of folders under the folders found at the level above
+	var folders []string
+	parents := []string{""}
+	for level := 0; level < depth && len(parents) > 0; level++ {
+		var children []string
+		for _, parent := range parents {
+			prefixes, err := c.listPrefixes(bucket, parent)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, prefixes...)
+		}
+		folders = append(folders, children...)
+		parents = children
 	}
 
-	// Build list of subdirectories up to specified depth
-	var keys []string
-	keySet := make(map[string]struct{})
-	for _, file := range out.Contents {
-		subDir := *file.Key
+	return folders, nil
+}
 
-		// Skip files, a folder will end with delimiter
-		if !strings.HasSuffix(subDir, Delimiter) {
-			continue
+// listPrefixes gets the folders directly under the prefix, across every page of results
+func (c *Client) listPrefixes(bucket string, prefix string) ([]string, error) {
+	var prefixes []string
+	err := c.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String(Delimiter),
+	}, func(out *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, commonPrefix := range out.CommonPrefixes {
+			prefixes = append(prefixes, aws.StringValue(commonPrefix.Prefix))
 		}
+		return true
+	})
+	return prefixes, err
+}
+
+func (c *Client) Put(file io.ReadSeeker, bucket string, key string) error {
+	//req, _ := c.s3Uploader.Upload()
+	req, _ := c.s3Client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		Body:              file,
+		ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+	})
+	return req.Send()
+}
+
+// Upload streams a file of unknown size, uploading it in parts
+func (c *Client) Upload(body io.Reader, bucket string, key string) error {
+	uploader := s3manager.NewUploaderWithClient(c.s3Client)
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	return err
+}
 
-		// Remove path below max depth
-		if folders := strings.Split(subDir, Delimiter); len(folders) > depth {
-			subDir = strings.Join(folders[:depth-1], Delimiter)
+// List gets the keys of all files under the prefix
+func (c *Client) List(bucket string, prefix string) ([]string, error) {
+	var keys []string
+	err := c.ListObjects(bucket, prefix, func(obj Object) error {
+		// Skip folders, which end with delimiter
+		if !strings.HasSuffix(obj.Key, Delimiter) {
+			keys = append(keys, obj.Key)
 		}
+		return nil
+	})
+	return keys, err
+}
 
-		if _, ok := keySet[subDir]; !ok {
-			keys = append(keys, subDir)
-			keySet[subDir] = struct{}{}
+// ListObjects calls fn for every object under the prefix in key order, fetching each page of results as needed,
+// listing stops at the first error returned by fn, which is then returned
+func (c *Client) ListObjects(bucket string, prefix string, fn func(obj Object) error) error {
+	var fnErr error
+	err := c.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(out *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range out.Contents {
+			fnErr = fn(Object{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         strings.Trim(aws.StringValue(obj.ETag), `"`),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+			if fnErr != nil {
+				return false
+			}
 		}
+		return true
+	})
+	if err != nil {
+		return err
 	}
-
-	return keys, nil
+	return fnErr
 }
 
-func (c *Client) Put(file io.ReadSeeker, bucket string, key string) error {
-	//req, _ := c.s3Uploader.Upload()
-	req, _ := c.s3Client.PutObjectRequest(&s3.PutObjectInput{
+// Get gets the contents of a file, which must be closed by the caller
+func (c *Client) Get(bucket string, key string) (io.ReadCloser, error) {
+	out, err := c.s3Client.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-		Body:   file,
 	})
-	return req.Send()
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Checksum gets the hex SHA-256 of a file stored by Put without downloading it,
+// empty if S3 has no checksum of the whole file, as for those uploaded in parts
+func (c *Client) Checksum(bucket string, key string) (string, error) {
+	out, err := c.s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Checksums of files uploaded in parts are of the parts, suffixed by the number of parts
+	checksum := aws.StringValue(out.ChecksumSHA256)
+	if checksum == "" || strings.Contains(checksum, "-") {
+		return "", nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum for [%s]: %w", key, err)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// DeleteObjects deletes all the given files, in batches if needed
+func (c *Client) DeleteObjects(bucket string, keys []string) error {
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > maxDeleteKeys {
+			batch = batch[:maxDeleteKeys]
+		}
+		keys = keys[len(batch):]
+
+		objects := make([]*s3.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
+		}
+		out, err := c.s3Client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		} else if len(out.Errors) > 0 {
+			return fmt.Errorf("could not delete %d files, first error: [%s] %s", len(out.Errors), aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+	}
+	return nil
 }
@@ -0,0 +1,274 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 records the keys of each delete request, reports the checksum of every file,
+// and lists the given keys in pages of the given size
+type fakeS3 struct {
+	s3iface.S3API
+
+	deleted  [][]string
+	checksum *string
+	keys     []string
+	pageSize int
+	pages    int
+}
+
+func (f *fakeS3) ListObjectsV2Pages(in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	// Group keys under the prefix into folders by the first delimiter after it
+	type entry struct {
+		key    string
+		folder bool
+	}
+	var entries []entry
+	seen := make(map[string]struct{})
+	prefix, delimiter := aws.StringValue(in.Prefix), aws.StringValue(in.Delimiter)
+	for _, key := range f.keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			folder := key[:len(prefix)+i+len(delimiter)]
+			if _, ok := seen[folder]; !ok {
+				seen[folder] = struct{}{}
+				entries = append(entries, entry{key: folder, folder: true})
+			}
+			continue
+		}
+		entries = append(entries, entry{key: key})
+	}
+
+	for start := 0; start < len(entries) || start == 0; start += f.pageSize {
+		end := start + f.pageSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		out := &s3.ListObjectsV2Output{}
+		for _, e := range entries[start:end] {
+			if e.folder {
+				out.CommonPrefixes = append(out.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(e.key)})
+			} else {
+				out.Contents = append(out.Contents, &s3.Object{
+					Key:          aws.String(e.key),
+					Size:         aws.Int64(int64(len(e.key))),
+					ETag:         aws.String(fmt.Sprintf("%q", e.key)),
+					LastModified: aws.Time(time.Unix(0, 0)),
+				})
+			}
+		}
+		f.pages++
+		if !fn(out, end == len(entries)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeS3) HeadObject(in *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{ChecksumSHA256: f.checksum}, nil
+}
+
+func (f *fakeS3) DeleteObjects(in *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	var keys []string
+	for _, obj := range in.Delete.Objects {
+		keys = append(keys, *obj.Key)
+	}
+	f.deleted = append(f.deleted, keys)
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func Test_Client_DeleteObjects(t *testing.T) {
+	tests := []struct {
+		name       string
+		numKeys    int
+		expBatches []int
+	}{
+		{
+			name:       "Happy path - Single batch",
+			numKeys:    3,
+			expBatches: []int{3},
+		},
+		{
+			name:       "Happy path - Multiple batches",
+			numKeys:    maxDeleteKeys + 1,
+			expBatches: []int{maxDeleteKeys, 1},
+		},
+		{
+			name: "Happy path - No keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{}
+			c := &Client{s3Client: fake}
+
+			keys := make([]string, tt.numKeys)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("game/2023-04-05/file%d", i)
+			}
+
+			require.NoError(t, c.DeleteObjects("bucket", keys))
+
+			require.Len(t, fake.deleted, len(tt.expBatches))
+			for i, size := range tt.expBatches {
+				assert.Len(t, fake.deleted[i], size)
+			}
+		})
+	}
+}
+
+func Test_Client_Checksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("save data"))
+
+	tests := []struct {
+		name        string
+		checksum    *string
+		expChecksum string
+	}{
+		{
+			name:        "Happy path",
+			checksum:    aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+			expChecksum: hex.EncodeToString(sum[:]),
+		},
+		{
+			name:     "Happy path - Uploaded in parts",
+			checksum: aws.String(base64.StdEncoding.EncodeToString(sum[:]) + "-3"),
+		},
+		{
+			name: "Happy path - No checksum",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{s3Client: &fakeS3{checksum: tt.checksum}}
+
+			got, err := c.Checksum("bucket", "game/2023-04-05/file")
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expChecksum, got)
+		})
+	}
+}
+
+// Keys of two backups, where neither has a folder key
+var listKeys = []string{
+	"GameOne/2023-04-05/save.dat",
+	"GameOne/2023-04-06/save.dat",
+	"GameOne/blobs/ab/abcd",
+	"GameTwo/",
+	"GameTwo/2023-04-05/save.dat",
+	"GameTwo/2023-04-05/world/level.dat",
+}
+
+func Test_Client_GetFolders(t *testing.T) {
+	tests := []struct {
+		name       string
+		depth      int
+		expFolders []string
+		expErr     bool
+	}{
+		{
+			name:       "Happy path",
+			depth:      2,
+			expFolders: []string{"GameOne/", "GameTwo/", "GameOne/2023-04-05/", "GameOne/2023-04-06/", "GameOne/blobs/", "GameTwo/2023-04-05/"},
+		},
+		{
+			name:       "Happy path - Single level",
+			depth:      1,
+			expFolders: []string{"GameOne/", "GameTwo/"},
+		},
+		{
+			name:   "Sad path - No depth",
+			depth:  0,
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{s3Client: &fakeS3{keys: listKeys, pageSize: 1}}
+
+			folders, err := c.GetFolders("bucket", tt.depth)
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expFolders, folders)
+		})
+	}
+}
+
+func Test_Client_List(t *testing.T) {
+	fake := &fakeS3{keys: listKeys, pageSize: 2}
+	c := &Client{s3Client: fake}
+
+	// Every page is listed, leaving out folder keys
+	keys, err := c.List("bucket", "GameTwo/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GameTwo/2023-04-05/save.dat", "GameTwo/2023-04-05/world/level.dat"}, keys)
+	assert.Equal(t, 2, fake.pages)
+}
+
+func Test_Client_ListObjects(t *testing.T) {
+	stop := fmt.Errorf("stop")
+
+	tests := []struct {
+		name    string
+		prefix  string
+		stopAt  int
+		expKeys []string
+		expErr  error
+	}{
+		{
+			name:    "Happy path",
+			prefix:  "GameOne/",
+			expKeys: []string{"GameOne/2023-04-05/save.dat", "GameOne/2023-04-06/save.dat", "GameOne/blobs/ab/abcd"},
+		},
+		{
+			name:   "Happy path - No objects",
+			prefix: "GameThree/",
+		},
+		{
+			name:    "Sad path - Stopped early",
+			prefix:  "GameOne/",
+			stopAt:  2,
+			expKeys: []string{"GameOne/2023-04-05/save.dat", "GameOne/2023-04-06/save.dat"},
+			expErr:  stop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{s3Client: &fakeS3{keys: listKeys, pageSize: 2}}
+
+			var keys []string
+			err := c.ListObjects("bucket", tt.prefix, func(obj Object) error {
+				keys = append(keys, obj.Key)
+				assert.Equal(t, int64(len(obj.Key)), obj.Size)
+				assert.Equal(t, obj.Key, obj.ETag)
+				assert.Equal(t, time.Unix(0, 0), obj.LastModified)
+				if len(keys) == tt.stopAt {
+					return stop
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expKeys, keys)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -13,6 +14,12 @@ const (
 	GetSessionMethod         = "GetSession"
 	GetFoldersMethod         = "GetFolders"
 	PutMethod                = "Put"
+	UploadMethod             = "Upload"
+	ListMethod               = "List"
+	ListObjectsMethod        = "ListObjects"
+	GetMethod                = "Get"
+	ChecksumMethod           = "Checksum"
+	DeleteObjectsMethod      = "DeleteObjects"
 )
 
 // Ensure MockClient implements ClientIFace
@@ -48,3 +55,52 @@ func (m *MockClient) Put(file io.ReadSeeker, bucket string, key string) error {
 	args := m.Called(file, bucket, key)
 	return args.Error(0)
 }
+
+// Upload consumes the body as a real upload would, passing on its contents as a reader
+func (m *MockClient) Upload(body io.Reader, bucket string, key string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	args := m.Called(bytes.NewReader(data), bucket, key)
+	return args.Error(0)
+}
+
+func (m *MockClient) List(bucket string, prefix string) ([]string, error) {
+	args := m.Called(bucket, prefix)
+	if keys := args.Get(0); keys != nil {
+		return keys.([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ListObjects calls fn for each of the objects returned by the mock, stopping at the first error
+func (m *MockClient) ListObjects(bucket string, prefix string, fn func(obj Object) error) error {
+	args := m.Called(bucket, prefix, fn)
+	if objects := args.Get(0); objects != nil {
+		for _, obj := range objects.([]Object) {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockClient) Get(bucket string, key string) (io.ReadCloser, error) {
+	args := m.Called(bucket, key)
+	if body := args.Get(0); body != nil {
+		return body.(io.ReadCloser), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockClient) Checksum(bucket string, key string) (string, error) {
+	args := m.Called(bucket, key)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockClient) DeleteObjects(bucket string, keys []string) error {
+	args := m.Called(bucket, keys)
+	return args.Error(0)
+}
@@ -15,6 +15,7 @@ type SessionIFace interface {
 
 	// Channel Messaging
 	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
 
 	// Interactions
 	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error
@@ -6,12 +6,13 @@ import (
 )
 
 const (
-	SessionApplicationCommandCreateMethod = "ApplicationCommandCreate"
-	SessionApplicationCommandsMethod      = "ApplicationCommands"
-	SessionApplicationCommandDeleteMethod = "ApplicationCommandDelete"
-	SessionChannelMessageSendMethod       = "ChannelMessageSend"
-	SessionInteractionRespondMethod       = "InteractionRespond"
-	SessionInteractionResponseEditMethod  = "InteractionResponseEdit"
+	SessionApplicationCommandCreateMethod  = "ApplicationCommandCreate"
+	SessionApplicationCommandsMethod       = "ApplicationCommands"
+	SessionApplicationCommandDeleteMethod  = "ApplicationCommandDelete"
+	SessionChannelMessageSendMethod        = "ChannelMessageSend"
+	SessionChannelMessageSendComplexMethod = "ChannelMessageSendComplex"
+	SessionInteractionRespondMethod        = "InteractionRespond"
+	SessionInteractionResponseEditMethod   = "InteractionResponseEdit"
 )
 
 // Ensure MockDiscordSession implements SessionIFace
@@ -47,6 +48,14 @@ func (m *MockDiscordSession) ChannelMessageSend(channelID string, content string
 	return nil, args.Error(1)
 }
 
+func (m *MockDiscordSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	args := m.Called(channelID, data)
+	if respMsg := args.Get(0); respMsg != nil {
+		return respMsg.(*discordgo.Message), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockDiscordSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
 	args := m.Called(interaction, resp)
 	return args.Error(0)
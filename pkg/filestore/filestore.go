@@ -0,0 +1,205 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	Delimiter = "/"
+
+	// Files are written under this prefix, then renamed into place once complete
+	tempPrefix = ".tmp-"
+)
+
+// Client stores files in a local directory, laid out as they would be in an S3 bucket.
+// In place of a bucket each method takes the root directory
+type Client struct{}
+
+func New() *Client {
+	return &Client{}
+}
+
+// Connect does nothing, the root directory is created on the first put
+func (c *Client) Connect() error {
+	return nil
+}
+
+// GetFolders gets every folder under the root directory up to the given depth, each ending with delimiter
+func (c *Client) GetFolders(root string, depth int) ([]string, error) {
+	if depth < 1 {
+		return nil, fmt.Errorf("subdirectory depth must be at least 1")
+	}
+
+	// Read a level at a time, as S3 lists the common prefixes of each
+	var folders []string
+	parents := []string{""}
+	for level := 0; level < depth && len(parents) > 0; level++ {
+		var children []string
+		for _, parent := range parents {
+			prefixes, err := listPrefixes(root, parent)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, prefixes...)
+		}
+		folders = append(folders, children...)
+		parents = children
+	}
+
+	return folders, nil
+}
+
+func (c *Client) Put(file io.ReadSeeker, root string, key string) error {
+	return c.Upload(file, root, key)
+}
+
+// Upload writes a file, replacing any existing file only once it has been completely written
+func (c *Client) Upload(body io.Reader, root string, key string) error {
+	filePath, err := keyPath(root, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filePath), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filePath)
+}
+
+// List gets the keys of all files under the prefix
+func (c *Client) List(root string, prefix string) ([]string, error) {
+	// Only the folder the prefix is in needs to be walked
+	folder := prefix[:strings.LastIndex(prefix, Delimiter)+1]
+
+	var keys []string
+	err := walk(root, folder, func(key string, d fs.DirEntry) error {
+		if !d.IsDir() && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+
+	// Sort by key rather than by folder, as S3 lists them
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Get gets the contents of a file, which must be closed by the caller
+func (c *Client) Get(root string, key string) (io.ReadCloser, error) {
+	filePath, err := keyPath(root, key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filePath)
+}
+
+// DeleteObjects deletes all the given files, along with any folders left empty
+func (c *Client) DeleteObjects(root string, keys []string) error {
+	for _, key := range keys {
+		filePath, err := keyPath(root, key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		// Folders only exist while they have files, as in S3
+		for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
+			if err := os.Remove(filepath.Join(root, filepath.FromSlash(dir))); err != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// listPrefixes gets the folders directly within the folder, each ending with delimiter
+func listPrefixes(root string, folder string) ([]string, error) {
+	dir, err := folderPath(root, folder)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), tempPrefix) {
+			prefixes = append(prefixes, folder+entry.Name()+Delimiter)
+		}
+	}
+
+	// Sort by key rather than by name, as S3 lists them
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+// walk calls fn with the key of every file and folder within the folder of the root directory, in lexical order
+func walk(root string, folder string, fn func(key string, d fs.DirEntry) error) error {
+	dir, err := folderPath(root, folder)
+	if err != nil {
+		return err
+	}
+
+	// Nothing has been stored there yet
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if filePath == dir || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), d)
+	})
+}
+
+// folderPath gets the path of the directory for a folder, which is either empty for the root directory
+// or ends with delimiter
+func folderPath(root string, folder string) (string, error) {
+	if folder == "" {
+		return root, nil
+	}
+	return keyPath(root, strings.TrimSuffix(folder, Delimiter))
+}
+
+// keyPath gets the path of the file for a key, which must be within the root directory
+func keyPath(root string, key string) (string, error) {
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) {
+		return "", fmt.Errorf("invalid key: [%s]", key)
+	}
+	return filepath.Join(root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,103 @@
+package filestore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client(t *testing.T) {
+	root := t.TempDir()
+	c := New()
+	require.NoError(t, c.Connect())
+
+	// Nothing is listed before anything is stored
+	keys, err := c.List(filepath.Join(root, "missing"), "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, key := range []string{"game/2023-04-05/world/level.dat", "game/2023-04-05/world.dat", "game/2023-04-06/world/level.dat"} {
+		require.NoError(t, c.Put(strings.NewReader(key), root, key))
+	}
+
+	keys, err = c.List(root, "game/2023-04-05/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game/2023-04-05/world.dat", "game/2023-04-05/world/level.dat"}, keys)
+
+	// Prefixes need not end at a folder
+	keys, err = c.List(root, "game/2023-04-05/world")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game/2023-04-05/world.dat", "game/2023-04-05/world/level.dat"}, keys)
+	keys, err = c.List(root, "game/2023-04-06/missing/")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	folders, err := c.GetFolders(root, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game/", "game/2023-04-05/", "game/2023-04-06/"}, folders)
+	folders, err = c.GetFolders(root, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game/", "game/2023-04-05/", "game/2023-04-06/", "game/2023-04-05/world/", "game/2023-04-06/world/"}, folders)
+
+	body, err := c.Get(root, "game/2023-04-06/world/level.dat")
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, "game/2023-04-06/world/level.dat", string(data))
+
+	// Folders left empty are removed
+	require.NoError(t, c.DeleteObjects(root, []string{"game/2023-04-06/world/level.dat", "game/missing"}))
+	_, err = os.Stat(filepath.Join(root, "game", "2023-04-06"))
+	assert.True(t, os.IsNotExist(err))
+	folders, err = c.GetFolders(root, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game/", "game/2023-04-05/"}, folders)
+}
+
+func Test_keyPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		expErr bool
+	}{
+		{
+			name: "Happy path",
+			key:  "game/2023-04-05/world/level.dat",
+		},
+		{
+			name:   "Sad path - Outside root",
+			key:    "game/../../escape",
+			expErr: true,
+		},
+		{
+			name:   "Sad path - Absolute",
+			key:    "/etc/passwd",
+			expErr: true,
+		},
+		{
+			name:   "Sad path - Root",
+			key:    ".",
+			expErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			got, err := keyPath(root, tt.key)
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(root, filepath.FromSlash(tt.key)), got)
+		})
+	}
+}
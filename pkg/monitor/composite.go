@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"time"
+)
+
+// Ensure CompositeClient implements ClientIFace
+var _ ClientIFace = (*CompositeClient)(nil)
+
+// CompositeClient considers the server inactive only once all of its monitors have seen no activity for the timeout
+type CompositeClient struct {
+	*inactivity
+	monitors []ActivityMonitor
+}
+
+func NewComposite(timeout time.Duration, monitors ...ActivityMonitor) *CompositeClient {
+	return &CompositeClient{
+		inactivity: newInactivity(timeout),
+		monitors:   monitors,
+	}
+}
+
+func (c *CompositeClient) Start(ports []int32) (chan struct{}, error) {
+	for i, m := range c.monitors {
+		if err := m.track(ports); err != nil {
+			// Release monitors already tracking
+			for _, started := range c.monitors[:i] {
+				started.Close()
+			}
+			return nil, err
+		}
+	}
+
+	go func() {
+		defer c.Close()
+		c.await(c.LastActivity)
+	}()
+	return c.done, nil
+}
+
+func (c *CompositeClient) Close() {
+	c.close(func() {
+		for _, m := range c.monitors {
+			m.Close()
+		}
+	})
+}
+
+// Extend pushes the shutdown deadline out by the duration, up to the max extension, and returns the time until shutdown
+func (c *CompositeClient) Extend(d time.Duration) time.Duration {
+	return c.extend(d, c.LastActivity())
+}
+
+// LastActivity gets the most recent activity seen by any monitor
+func (c *CompositeClient) LastActivity() time.Time {
+	var last time.Time
+	for _, m := range c.monitors {
+		if t := m.LastActivity(); t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
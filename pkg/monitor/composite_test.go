@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CompositeClient_Start(t *testing.T) {
+	timeout := time.Minute
+	ports := []int32{1234}
+
+	// Speed up check rate
+	defer func(origRate time.Duration) {
+		checkRate = origRate
+	}(checkRate)
+	checkRate = time.Millisecond
+
+	tests := []struct {
+		name         string
+		lastActivity []time.Duration // Time since the last activity of each monitor
+		expInactive  bool
+	}{
+		{
+			name:         "Happy path - All monitors inactive",
+			lastActivity: []time.Duration{2 * timeout, 3 * timeout},
+			expInactive:  true,
+		},
+		{
+			name:         "Happy path - One monitor active",
+			lastActivity: []time.Duration{2 * timeout, 0},
+		},
+		{
+			name:         "Happy path - All monitors active",
+			lastActivity: []time.Duration{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var monitors []ActivityMonitor
+			for _, since := range tt.lastActivity {
+				m := new(mockActivityMonitor)
+				m.On(mockActivityMonitorTrackMethod, ports).Return(nil)
+				m.On(mockActivityMonitorLastActivityMethod).Return(time.Now().Add(-since))
+				m.On(CloseMethod).Return()
+				monitors = append(monitors, m)
+			}
+
+			c := NewComposite(timeout, monitors...)
+			defer c.Close()
+			done, err := c.Start(ports)
+			require.NoError(t, err)
+
+			select {
+			case <-done:
+				assert.True(t, tt.expInactive, "Channel not expected to be closed")
+			case <-time.After(50 * time.Millisecond):
+				assert.False(t, tt.expInactive, "Channel was not closed")
+			}
+
+			// Monitors are always closed along with the composite
+			c.Close()
+			for _, m := range monitors {
+				m.(*mockActivityMonitor).AssertCalled(t, CloseMethod)
+			}
+		})
+	}
+}
+
+func Test_CompositeClient_Start_Error(t *testing.T) {
+	mockErr := errors.New("mock error")
+
+	started := new(mockActivityMonitor)
+	started.On(mockActivityMonitorTrackMethod, mock.Anything).Return(nil)
+	started.On(CloseMethod).Return()
+	failed := new(mockActivityMonitor)
+	failed.On(mockActivityMonitorTrackMethod, mock.Anything).Return(mockErr)
+
+	c := NewComposite(time.Minute, started, failed)
+	_, err := c.Start(nil)
+
+	assert.ErrorIs(t, err, mockErr)
+	started.AssertCalled(t, CloseMethod)
+	failed.AssertNotCalled(t, CloseMethod)
+}
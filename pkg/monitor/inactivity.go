@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// Settings control when the server is considered inactive
+type Settings struct {
+	Timeout      time.Duration // Time without activity before the server is inactive
+	Grace        time.Duration // Time after rearming before any inactivity is counted
+	Warning      time.Duration // Lead time before inactivity to send a warning, none if zero
+	MaxExtension time.Duration // Furthest an extension can push the shutdown from now, no extensions if zero
+}
+
+// inactivity decides when activity has lapsed, it is shared by every monitor
+type inactivity struct {
+	timeout      time.Duration
+	graceEnd     time.Time // Inactivity is not counted before this time
+	warning      time.Duration
+	maxExtension time.Duration
+	warned       bool
+	rearmMu      sync.Mutex
+
+	checkRate time.Duration
+	warnings  chan time.Duration
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newInactivity(timeout time.Duration) *inactivity {
+	return &inactivity{
+		timeout:   timeout,
+		checkRate: checkRate,
+		warnings:  make(chan time.Duration, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// Rearm applies new settings and restarts the inactivity countdown, keeping any longer grace or extension
+func (i *inactivity) Rearm(s Settings) {
+	i.rearmMu.Lock()
+	defer i.rearmMu.Unlock()
+
+	i.timeout = s.Timeout
+	if graceEnd := time.Now().Add(s.Grace); graceEnd.After(i.graceEnd) {
+		i.graceEnd = graceEnd
+	}
+	i.warning = s.Warning
+	i.maxExtension = s.MaxExtension
+	i.warned = false
+}
+
+// extend pushes the shutdown deadline out, up to the max extension from now, and returns the time until shutdown
+func (i *inactivity) extend(d time.Duration, last time.Time) time.Duration {
+	i.rearmMu.Lock()
+	defer i.rearmMu.Unlock()
+
+	if i.graceEnd.After(last) {
+		last = i.graceEnd
+	}
+	deadline := last.Add(i.timeout + d)
+	if max := time.Now().Add(i.maxExtension); deadline.After(max) {
+		deadline = max
+	}
+
+	// Inactivity is counted from the point that leaves the timeout before the new deadline
+	if graceEnd := deadline.Add(-i.timeout); graceEnd.After(last) {
+		i.graceEnd = graceEnd
+		last = graceEnd
+	}
+	return time.Until(last.Add(i.timeout))
+}
+
+// Warning receives the time remaining once inactivity is within the warning lead time
+func (i *inactivity) Warning() <-chan time.Duration {
+	return i.warnings
+}
+
+// await blocks until the time since the last activity surpasses the timeout, or done is closed
+func (i *inactivity) await(lastActivity func() time.Time) {
+	for {
+		select {
+		case <-i.done:
+			return
+		default:
+			time.Sleep(i.checkRate)
+		}
+
+		if i.check(lastActivity()) {
+			return
+		}
+	}
+}
+
+// check reports whether the server is inactive, warning once per lapse in activity
+func (i *inactivity) check(last time.Time) bool {
+	i.rearmMu.Lock()
+	defer i.rearmMu.Unlock()
+
+	if i.graceEnd.After(last) {
+		last = i.graceEnd
+	}
+	remaining := i.timeout - time.Since(last)
+	if remaining <= 0 {
+		return true
+	}
+
+	if remaining > i.warning {
+		// Activity resumed, warn again if it lapses
+		i.warned = false
+	} else if !i.warned {
+		i.warned = true
+		select {
+		case i.warnings <- remaining:
+		default:
+		}
+	}
+	return false
+}
+
+// close releases the monitor once, signalling inactivity to any waiting on done
+func (i *inactivity) close(release func()) {
+	i.closeOnce.Do(func() {
+		release()
+		close(i.done)
+	})
+}
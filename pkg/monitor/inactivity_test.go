@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_inactivity_check(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    *Settings     // Rearmed with these settings if set
+		sinceLast   time.Duration // Time since the last activity
+		expInactive bool
+		expWarning  bool
+	}{
+		{
+			name:        "Happy path - Inactive",
+			sinceLast:   2 * time.Minute,
+			expInactive: true,
+		},
+		{
+			name:      "Happy path - Active",
+			sinceLast: time.Second,
+		},
+		{
+			name:      "Happy path - Rearm restarts countdown",
+			settings:  &Settings{Timeout: time.Minute},
+			sinceLast: 2 * time.Minute,
+		},
+		{
+			name:      "Happy path - Within grace period",
+			settings:  &Settings{Timeout: time.Second, Grace: time.Minute},
+			sinceLast: 2 * time.Minute,
+		},
+		{
+			name:       "Happy path - Within warning lead time",
+			settings:   &Settings{Timeout: time.Minute, Warning: 2 * time.Minute},
+			sinceLast:  2 * time.Minute,
+			expWarning: true,
+		},
+		{
+			name:      "Happy path - Before warning lead time",
+			settings:  &Settings{Timeout: time.Minute, Warning: time.Second},
+			sinceLast: 2 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newInactivity(time.Minute)
+			if tt.settings != nil {
+				i.Rearm(*tt.settings)
+			}
+
+			inactive := i.check(time.Now().Add(-tt.sinceLast))
+			assert.Equal(t, tt.expInactive, inactive)
+
+			select {
+			case remaining := <-i.Warning():
+				assert.True(t, tt.expWarning, "Warning not expected")
+				assert.Greater(t, remaining, time.Duration(0))
+				assert.LessOrEqual(t, remaining, tt.settings.Warning)
+			default:
+				assert.False(t, tt.expWarning, "Warning was not sent")
+			}
+
+			// Warning is only sent once per lapse in activity
+			i.check(time.Now().Add(-tt.sinceLast))
+			assert.Empty(t, i.Warning())
+		})
+	}
+}
+
+func Test_inactivity_extend(t *testing.T) {
+	timeout := 10 * time.Minute
+
+	tests := []struct {
+		name         string
+		maxExtension time.Duration
+		extension    time.Duration
+		expRemaining time.Duration
+	}{
+		{
+			name:         "Happy path - Extended",
+			maxExtension: time.Hour,
+			extension:    20 * time.Minute,
+			expRemaining: 30 * time.Minute,
+		},
+		{
+			name:         "Happy path - Capped at max extension",
+			maxExtension: time.Hour,
+			extension:    2 * time.Hour,
+			expRemaining: time.Hour,
+		},
+		{
+			name:         "Happy path - Extensions disabled",
+			extension:    20 * time.Minute,
+			expRemaining: timeout,
+		},
+		{
+			name:         "Happy path - Never shortened",
+			maxExtension: 5 * time.Minute,
+			extension:    20 * time.Minute,
+			expRemaining: timeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newInactivity(timeout)
+			i.Rearm(Settings{Timeout: timeout, MaxExtension: tt.maxExtension})
+
+			remaining := i.extend(tt.extension, time.Now())
+
+			assert.InDelta(t, tt.expRemaining, remaining, float64(time.Second))
+			assert.False(t, i.check(time.Now().Add(-2*timeout)), "Extension not applied")
+		})
+	}
+}
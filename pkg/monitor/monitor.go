@@ -14,18 +14,30 @@ const device = "any"
 
 var checkRate = time.Second
 
-// Ensure Client implements ClientIFace
-var _ ClientIFace = (*Client)(nil)
+// Ensure Client implements ActivityMonitor
+var _ ActivityMonitor = (*Client)(nil)
 
 type ClientIFace interface {
 	Start(ports []int32) (chan struct{}, error)
 	Close()
+	Rearm(s Settings)
+	Warning() <-chan time.Duration
+	Extend(d time.Duration) time.Duration
 }
 
+// ActivityMonitor is a monitor that can be combined with others into a composite
+type ActivityMonitor interface {
+	ClientIFace
+	LastActivity() time.Time
+
+	// track begins recording activity without acting on the timeout
+	track(ports []int32) error
+}
+
+// Client considers the server inactive once no packets have been seen on its ports for the timeout
 type Client struct {
+	*inactivity
 	lastTime time.Time
-	timeout  time.Duration
-	done     chan struct{}
 	mu       sync.Mutex
 
 	handler packetHandler
@@ -42,22 +54,52 @@ type packetSource interface {
 
 func New(timeout time.Duration) *Client {
 	return &Client{
-		lastTime: time.Now(),
-		timeout:  timeout,
-		done:     make(chan struct{}),
+		inactivity: newInactivity(timeout),
+		lastTime:   time.Now(),
 	}
 }
 
 func (c *Client) Start(ports []int32) (chan struct{}, error) {
+	if err := c.listen(ports); err != nil {
+		return nil, err
+	}
+	return c.start(), nil
+}
+
+func (c *Client) Close() {
+	c.close(c.handler.Close)
+}
+
+// Extend pushes the shutdown deadline out by the duration, up to the max extension, and returns the time until shutdown
+func (c *Client) Extend(d time.Duration) time.Duration {
+	return c.extend(d, c.LastActivity())
+}
+
+// LastActivity gets the timestamp of the most recent packet
+func (c *Client) LastActivity() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastTime
+}
+
+func (c *Client) track(ports []int32) error {
+	if err := c.listen(ports); err != nil {
+		return err
+	}
+	c.startPacketHandler()
+	return nil
+}
+
+func (c *Client) listen(ports []int32) error {
 	filter := getPortFilter(ports)
 	if filter == "" {
-		return nil, fmt.Errorf("no ports specified")
+		return fmt.Errorf("no ports specified")
 	}
 
 	// Get PCAP handler
 	h, err := pcap.OpenLive(device, 0, false, pcap.BlockForever)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	c.handler = h
 	c.packets = gopacket.NewPacketSource(h, h.LinkType())
@@ -65,15 +107,10 @@ func (c *Client) Start(ports []int32) (chan struct{}, error) {
 	// Set port filter
 	if err := h.SetBPFFilter(filter); err != nil {
 		h.Close()
-		return nil, err
+		return err
 	}
 
-	return c.start(), nil
-}
-
-func (c *Client) Close() {
-	c.handler.Close()
-	close(c.done)
+	return nil
 }
 
 func (c *Client) start() chan struct{} {
@@ -98,19 +135,7 @@ func (c *Client) monitor() {
 	// Monitors time since the last packet until it surpasses the timeout value
 	go func() {
 		defer c.Close()
-
-		var diff time.Duration
-		for diff < c.timeout {
-			select {
-			case <-c.done:
-				return
-			default:
-				time.Sleep(checkRate)
-				c.mu.Lock()
-				diff = time.Since(c.lastTime)
-				c.mu.Unlock()
-			}
-		}
+		c.await(c.LastActivity)
 	}()
 }
 
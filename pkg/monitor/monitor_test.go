@@ -64,7 +64,7 @@ func Test_Client_start(t *testing.T) {
 	// Ensure done channel and packet handler were closed
 	select {
 	case <-done:
-	case <-time.After(time.Millisecond):
+	case <-time.After(time.Second):
 		assert.Fail(t, "Channel was not closed")
 	}
 	pcapMock.AssertCalled(t, mockPacketHandlerCloseMethod)
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+var pollRate = 30 * time.Second
+
+// Ensure PlayerClient implements ActivityMonitor
+var _ ActivityMonitor = (*PlayerClient)(nil)
+
+// PlayerCounter gets the number of players currently online, along with an error if some could not be counted
+type PlayerCounter interface {
+	PlayerCount() (int, error)
+}
+
+// PlayerClient considers the server inactive once no players have been online for the timeout
+type PlayerClient struct {
+	*inactivity
+	counter  PlayerCounter
+	lastTime time.Time
+	pollRate time.Duration
+	mu       sync.Mutex
+}
+
+func NewPlayers(timeout time.Duration, counter PlayerCounter) *PlayerClient {
+	return &PlayerClient{
+		inactivity: newInactivity(timeout),
+		counter:    counter,
+		lastTime:   time.Now(),
+		pollRate:   pollRate,
+	}
+}
+
+// Start begins polling for players, ports are unused since players are counted by the game's query protocol
+func (c *PlayerClient) Start(ports []int32) (chan struct{}, error) {
+	c.track(ports)
+	go func() {
+		defer c.Close()
+		c.await(c.LastActivity)
+	}()
+	return c.done, nil
+}
+
+func (c *PlayerClient) Close() {
+	c.close(func() {})
+}
+
+// Extend pushes the shutdown deadline out by the duration, up to the max extension, and returns the time until shutdown
+func (c *PlayerClient) Extend(d time.Duration) time.Duration {
+	return c.extend(d, c.LastActivity())
+}
+
+// LastActivity gets the time players were last seen online
+func (c *PlayerClient) LastActivity() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastTime
+}
+
+func (c *PlayerClient) track(ports []int32) error {
+	go func() {
+		ticker := time.NewTicker(c.pollRate)
+		defer ticker.Stop()
+		for {
+			c.poll()
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *PlayerClient) poll() {
+	// A failed count is unknown rather than activity, so a server whose players can never be counted is not kept up forever,
+	// while any players counted despite the error are still activity
+	if count, _ := c.counter.PlayerCount(); count > 0 {
+		c.mu.Lock()
+		c.lastTime = time.Now()
+		c.mu.Unlock()
+	}
+}
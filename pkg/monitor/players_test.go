@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PlayerClient_Start(t *testing.T) {
+	timeout := 50 * time.Millisecond
+
+	// Speed up check and poll rates
+	defer func(origCheckRate time.Duration, origPollRate time.Duration) {
+		checkRate = origCheckRate
+		pollRate = origPollRate
+	}(checkRate, pollRate)
+	checkRate = time.Millisecond
+	pollRate = time.Millisecond
+
+	tests := []struct {
+		name        string
+		count       int
+		countErr    error
+		expInactive bool
+	}{
+		{
+			name:        "Happy path - No players online",
+			expInactive: true,
+		},
+		{
+			name:  "Happy path - Players online",
+			count: 2,
+		},
+		{
+			name:        "Sad path - Players unknown",
+			countErr:    errors.New("mock error"),
+			expInactive: true,
+		},
+		{
+			name:     "Sad path - Some players unknown",
+			count:    1,
+			countErr: errors.New("mock error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := new(mockPlayerCounter)
+			counter.On(mockPlayerCounterPlayerCountMethod).Return(tt.count, tt.countErr)
+
+			c := NewPlayers(timeout, counter)
+			defer c.Close()
+			done, err := c.Start(nil)
+			assert.NoError(t, err)
+
+			select {
+			case <-done:
+				assert.True(t, tt.expInactive, "Channel not expected to be closed")
+			case <-time.After(4 * timeout):
+				assert.False(t, tt.expInactive, "Channel was not closed")
+			}
+		})
+	}
+}
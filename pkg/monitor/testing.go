@@ -8,8 +8,11 @@ import (
 )
 
 const (
-	StartMethod = "Start"
-	CloseMethod = "Close"
+	StartMethod   = "Start"
+	CloseMethod   = "Close"
+	RearmMethod   = "Rearm"
+	WarningMethod = "Warning"
+	ExtendMethod  = "Extend"
 )
 
 // Ensure MockClient implements ClientIFace
@@ -31,6 +34,20 @@ func (m *MockClient) Close() {
 	m.Called()
 }
 
+func (m *MockClient) Rearm(s Settings) {
+	m.Called(s)
+}
+
+func (m *MockClient) Warning() <-chan time.Duration {
+	args := m.Called()
+	return args.Get(0).(chan time.Duration)
+}
+
+func (m *MockClient) Extend(d time.Duration) time.Duration {
+	args := m.Called(d)
+	return args.Get(0).(time.Duration)
+}
+
 const (
 	mockPacketHandlerCloseMethod   = "Close"
 	mockPacketHandlerPacketsMethod = "Packets"
@@ -65,3 +82,38 @@ func (m *mockPacket) Metadata() *gopacket.PacketMetadata {
 	metadata.Timestamp = m.Timestamp
 	return metadata
 }
+
+const (
+	mockPlayerCounterPlayerCountMethod    = "PlayerCount"
+	mockActivityMonitorLastActivityMethod = "LastActivity"
+	mockActivityMonitorTrackMethod        = "track"
+)
+
+// Ensure mockPlayerCounter implements PlayerCounter
+var _ PlayerCounter = (*mockPlayerCounter)(nil)
+
+type mockPlayerCounter struct {
+	mock.Mock
+}
+
+func (m *mockPlayerCounter) PlayerCount() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+// Ensure mockActivityMonitor implements ActivityMonitor
+var _ ActivityMonitor = (*mockActivityMonitor)(nil)
+
+type mockActivityMonitor struct {
+	MockClient
+}
+
+func (m *mockActivityMonitor) LastActivity() time.Time {
+	args := m.Called()
+	return args.Get(0).(time.Time)
+}
+
+func (m *mockActivityMonitor) track(ports []int32) error {
+	args := m.Called(ports)
+	return args.Error(0)
+}
@@ -0,0 +1,236 @@
+package a2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	maxPacketSize = 1400
+
+	// Packet headers
+	simpleHeader int32 = -1
+	splitHeader  int32 = -2
+
+	// Request types
+	infoRequest   byte = 0x54
+	playerRequest byte = 0x55
+
+	// Response types
+	challengeResponse byte = 0x41
+	infoResponse      byte = 0x49
+	playerResponse    byte = 0x44
+
+	infoPayload = "Source Engine Query\x00"
+
+	// Limit on challenge round trips before giving up
+	maxChallenges = 3
+)
+
+var DefaultTimeout = 2 * time.Second
+
+// Info is the server information returned by A2S_INFO
+type Info struct {
+	Protocol    byte
+	Name        string
+	Map         string
+	Folder      string
+	Game        string
+	AppID       uint16
+	Players     uint8
+	MaxPlayers  uint8
+	Bots        uint8
+	ServerType  byte
+	Environment byte
+	Visibility  bool
+	VAC         bool
+	Version     string
+}
+
+// Player is a single entry returned by A2S_PLAYER
+type Player struct {
+	Index    uint8
+	Name     string
+	Score    int32
+	Duration time.Duration
+}
+
+// Client queries a Source engine server over UDP
+type Client struct {
+	address string
+	Timeout time.Duration
+}
+
+func New(address string) *Client {
+	return &Client{
+		address: address,
+		Timeout: DefaultTimeout,
+	}
+}
+
+func (c *Client) Info() (*Info, error) {
+	resp, err := c.query(infoRequest, []byte(infoPayload), false, infoResponse)
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(resp)
+}
+
+func (c *Client) Players() ([]Player, error) {
+	resp, err := c.query(playerRequest, nil, true, playerResponse)
+	if err != nil {
+		return nil, err
+	}
+	return parsePlayers(resp)
+}
+
+// query sends a request and returns the response body after the type byte, answering any challenges
+func (c *Client) query(reqType byte, payload []byte, alwaysChallenge bool, respType byte) (*reader, error) {
+	conn, err := net.Dial("udp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Some requests must always carry a challenge, which is requested with -1
+	var challenge []byte
+	if alwaysChallenge {
+		challenge = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	}
+
+	for i := 0; i < maxChallenges; i++ {
+		req := new(bytes.Buffer)
+		binary.Write(req, binary.LittleEndian, simpleHeader)
+		req.WriteByte(reqType)
+		req.Write(payload)
+		req.Write(challenge)
+
+		if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(req.Bytes()); err != nil {
+			return nil, err
+		}
+
+		resp, err := readResponse(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := resp.byte(); t {
+		case respType:
+			return resp, resp.err
+		case challengeResponse:
+			challenge = resp.bytes(4)
+			if resp.err != nil {
+				return nil, resp.err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected response type: [0x%x]", t)
+		}
+	}
+
+	return nil, errors.New("server kept responding with challenges")
+}
+
+// readResponse reads a full response, reassembling split packets if needed
+func readResponse(conn net.Conn) (*reader, error) {
+	var parts [][]byte
+	var total int
+	for {
+		buf := make([]byte, maxPacketSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		packet := &reader{buf: buf[:n]}
+
+		switch header := packet.int32(); header {
+		case simpleHeader:
+			return packet, packet.err
+
+		case splitHeader:
+			// Source split packet: ID, total packets, packet number, size
+			id := packet.int32()
+			if id < 0 {
+				return nil, errors.New("compressed split responses are not supported")
+			}
+			packetTotal := int(packet.byte())
+			number := int(packet.byte())
+			packet.uint16()
+			if packet.err != nil {
+				return nil, packet.err
+			} else if packetTotal == 0 || number >= packetTotal {
+				return nil, fmt.Errorf("invalid split packet [%d] of [%d]", number, packetTotal)
+			}
+
+			if parts == nil {
+				parts = make([][]byte, packetTotal)
+			}
+			if number < len(parts) && parts[number] == nil {
+				parts[number] = packet.remaining()
+				total++
+			}
+			if total < len(parts) {
+				continue
+			}
+
+			// Reassembled payload begins with its own simple header
+			full := &reader{buf: bytes.Join(parts, nil)}
+			if full.int32() != simpleHeader {
+				return nil, errors.New("invalid split response payload")
+			}
+			return full, full.err
+
+		default:
+			return nil, fmt.Errorf("invalid packet header: [%d]", header)
+		}
+	}
+}
+
+func parseInfo(r *reader) (*Info, error) {
+	info := &Info{
+		Protocol:    r.byte(),
+		Name:        r.string(),
+		Map:         r.string(),
+		Folder:      r.string(),
+		Game:        r.string(),
+		AppID:       r.uint16(),
+		Players:     r.byte(),
+		MaxPlayers:  r.byte(),
+		Bots:        r.byte(),
+		ServerType:  r.byte(),
+		Environment: r.byte(),
+		Visibility:  r.byte() == 1,
+		VAC:         r.byte() == 1,
+		Version:     r.string(),
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid info response: %w", r.err)
+	}
+	return info, nil
+}
+
+func parsePlayers(r *reader) ([]Player, error) {
+	count := int(r.byte())
+	players := make([]Player, 0, count)
+	for i := 0; i < count; i++ {
+		p := Player{
+			Index: r.byte(),
+			Name:  r.string(),
+			Score: r.int32(),
+		}
+		seconds := math.Float32frombits(uint32(r.int32()))
+		p.Duration = time.Duration(float64(seconds) * float64(time.Second))
+		players = append(players, p)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid player response: %w", r.err)
+	}
+	return players, nil
+}
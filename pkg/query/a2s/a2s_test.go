@@ -0,0 +1,187 @@
+package a2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testChallenge = []byte{0x0A, 0x0B, 0x0C, 0x0D}
+
+var testInfo = &Info{
+	Protocol:    17,
+	Name:        "Test Server",
+	Map:         "de_dust2",
+	Folder:      "csgo",
+	Game:        "Counter-Strike",
+	AppID:       730,
+	Players:     2,
+	MaxPlayers:  10,
+	Bots:        0,
+	ServerType:  'd',
+	Environment: 'l',
+	Visibility:  false,
+	VAC:         true,
+	Version:     "1.0.0",
+}
+
+var testPlayers = []Player{
+	{Index: 0, Name: "alice", Score: 12, Duration: 90 * time.Second},
+	{Index: 1, Name: "bob", Score: 3, Duration: 30 * time.Second},
+}
+
+func Test_Client_Info(t *testing.T) {
+	addr := startFakeServer(t, 0)
+
+	info, err := New(addr).Info()
+
+	require.NoError(t, err)
+	assert.Equal(t, testInfo, info)
+}
+
+func Test_Client_Players(t *testing.T) {
+	tests := []struct {
+		name      string
+		splitSize int
+	}{
+		{
+			name: "Happy path - Single packet",
+		},
+		{
+			name:      "Happy path - Split packets",
+			splitSize: 16,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startFakeServer(t, tt.splitSize)
+
+			players, err := New(addr).Players()
+
+			require.NoError(t, err)
+			assert.Equal(t, testPlayers, players)
+		})
+	}
+}
+
+func Test_Client_Timeout(t *testing.T) {
+	// Listener that never responds
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	c := New(conn.LocalAddr().String())
+	c.Timeout = 50 * time.Millisecond
+	_, err = c.Info()
+
+	require.Error(t, err)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
+
+// startFakeServer answers A2S requests, requiring a challenge and optionally splitting responses
+func startFakeServer(t *testing.T, splitSize int) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			reqType := req[4]
+
+			// Challenge any request not ending with the expected challenge
+			if !bytes.HasSuffix(req, testChallenge) {
+				resp := append(simplePacket(challengeResponse), testChallenge...)
+				conn.WriteTo(resp, addr)
+				continue
+			}
+
+			var resp []byte
+			switch reqType {
+			case infoRequest:
+				resp = buildInfo(testInfo)
+			case playerRequest:
+				resp = buildPlayers(testPlayers)
+			default:
+				continue
+			}
+
+			for _, packet := range splitPackets(resp, splitSize) {
+				conn.WriteTo(packet, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func simplePacket(respType byte) []byte {
+	return []byte{0xFF, 0xFF, 0xFF, 0xFF, respType}
+}
+
+func buildInfo(info *Info) []byte {
+	b := bytes.NewBuffer(simplePacket(infoResponse))
+	b.WriteByte(info.Protocol)
+	for _, s := range []string{info.Name, info.Map, info.Folder, info.Game} {
+		b.WriteString(s + "\x00")
+	}
+	binary.Write(b, binary.LittleEndian, info.AppID)
+	b.Write([]byte{info.Players, info.MaxPlayers, info.Bots, info.ServerType, info.Environment, 0, 1})
+	b.WriteString(info.Version + "\x00")
+	return b.Bytes()
+}
+
+func buildPlayers(players []Player) []byte {
+	b := bytes.NewBuffer(simplePacket(playerResponse))
+	b.WriteByte(byte(len(players)))
+	for _, p := range players {
+		b.WriteByte(p.Index)
+		b.WriteString(p.Name + "\x00")
+		binary.Write(b, binary.LittleEndian, p.Score)
+		binary.Write(b, binary.LittleEndian, math.Float32bits(float32(p.Duration.Seconds())))
+	}
+	return b.Bytes()
+}
+
+// splitPackets splits a response into Source split packets in reverse order, or returns it whole if size is zero
+func splitPackets(resp []byte, size int) [][]byte {
+	if size == 0 {
+		return [][]byte{resp}
+	}
+
+	var chunks [][]byte
+	for len(resp) > 0 {
+		n := size
+		if len(resp) < n {
+			n = len(resp)
+		}
+		chunks = append(chunks, resp[:n])
+		resp = resp[n:]
+	}
+
+	packets := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		b := new(bytes.Buffer)
+		binary.Write(b, binary.LittleEndian, splitHeader)
+		binary.Write(b, binary.LittleEndian, int32(1))
+		b.WriteByte(byte(len(chunks)))
+		b.WriteByte(byte(i))
+		binary.Write(b, binary.LittleEndian, uint16(maxPacketSize))
+		b.Write(chunk)
+		packets[len(chunks)-1-i] = b.Bytes()
+	}
+	return packets
+}
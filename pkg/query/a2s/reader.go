@@ -0,0 +1,70 @@
+package a2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errShortPacket = errors.New("packet too short")
+
+// reader decodes little-endian packet fields, the first error is kept and later reads return zero values
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	} else if r.pos+n > len(r.buf) {
+		r.err = errShortPacket
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) int32() int32 {
+	if b := r.bytes(4); b != nil {
+		return int32(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// string reads a null terminated string
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end < 0 {
+		r.err = errShortPacket
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+func (r *reader) remaining() []byte {
+	b := r.buf[r.pos:]
+	r.pos = len(r.buf)
+	return b
+}
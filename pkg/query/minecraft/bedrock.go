@@ -0,0 +1,134 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	unconnectedPingPacket = 0x01
+	unconnectedPongPacket = 0x1C
+
+	maxPongSize = 1500
+
+	// Minimum fields in the pong server ID: edition, MOTD, protocol, version, players, max players
+	minBedrockFields = 6
+)
+
+// Identifies offline RakNet messages
+var raknetMagic = []byte{0x00, 0xFF, 0xFF, 0x00, 0xFE, 0xFE, 0xFE, 0xFE, 0xFD, 0xFD, 0xFD, 0xFD, 0x12, 0x34, 0x56, 0x78}
+
+// BedrockStatus is the server status advertised in a RakNet unconnected pong
+type BedrockStatus struct {
+	Edition    string
+	MOTD       string
+	Protocol   int
+	Version    string
+	Players    int
+	MaxPlayers int
+	ServerID   string
+	LevelName  string
+	GameMode   string
+}
+
+// BedrockClient queries a Bedrock Edition server over UDP
+type BedrockClient struct {
+	address string
+	Timeout time.Duration
+}
+
+func NewBedrock(address string) *BedrockClient {
+	return &BedrockClient{
+		address: address,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Status sends an unconnected ping and parses the server ID from the pong
+func (c *BedrockClient) Status() (*BedrockStatus, error) {
+	conn, err := net.Dial("udp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+
+	// Ping: time, magic, client GUID
+	req := new(bytes.Buffer)
+	req.WriteByte(unconnectedPingPacket)
+	binary.Write(req, binary.BigEndian, time.Now().UnixMilli())
+	req.Write(raknetMagic)
+	binary.Write(req, binary.BigEndian, int64(0))
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxPongSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return parsePong(buf[:n])
+}
+
+func parsePong(packet []byte) (*BedrockStatus, error) {
+	// Pong: ID, time, server GUID, magic, server ID string
+	r := bytes.NewReader(packet)
+	var pong struct {
+		ID       byte
+		Time     int64
+		GUID     int64
+		Magic    [16]byte
+		IDLength uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &pong); err != nil {
+		return nil, fmt.Errorf("invalid pong: %w", err)
+	} else if pong.ID != unconnectedPongPacket {
+		return nil, fmt.Errorf("unexpected packet id: [0x%x]", pong.ID)
+	} else if !bytes.Equal(pong.Magic[:], raknetMagic) {
+		return nil, errors.New("invalid pong magic")
+	} else if int(pong.IDLength) > r.Len() {
+		return nil, errors.New("invalid pong: server ID too long")
+	}
+	serverID := make([]byte, pong.IDLength)
+	r.Read(serverID)
+
+	fields := strings.Split(string(serverID), ";")
+	if len(fields) < minBedrockFields {
+		return nil, fmt.Errorf("invalid server ID: [%s]", serverID)
+	}
+
+	status := &BedrockStatus{
+		Edition: fields[0],
+		MOTD:    fields[1],
+		Version: fields[3],
+	}
+	var err error
+	if status.Protocol, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid protocol version: [%s]", fields[2])
+	}
+	if status.Players, err = strconv.Atoi(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid player count: [%s]", fields[4])
+	}
+	if status.MaxPlayers, err = strconv.Atoi(fields[5]); err != nil {
+		return nil, fmt.Errorf("invalid max players: [%s]", fields[5])
+	}
+
+	// Remaining fields are optional
+	optional := []*string{&status.ServerID, &status.LevelName, &status.GameMode}
+	for i, field := range fields[minBedrockFields:] {
+		if i >= len(optional) {
+			break
+		}
+		*optional[i] = field
+	}
+	return status, nil
+}
@@ -0,0 +1,172 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// Protocol version sent when the client version is unknown
+	anyProtocolVersion = -1
+
+	handshakePacket     = 0x00
+	statusRequestPacket = 0x00
+	statusNextState     = 1
+
+	// Upper bounds to reject malformed responses before allocating
+	maxVarIntBytes  = 5
+	maxStatusLength = 1 << 20
+)
+
+var DefaultTimeout = 2 * time.Second
+
+// Status is the server status returned by a Server List Ping
+type Status struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int      `json:"max"`
+		Online int      `json:"online"`
+		Sample []Player `json:"sample"`
+	} `json:"players"`
+
+	// Either a plain string or a chat component
+	Description json.RawMessage `json:"description"`
+}
+
+// Player is a single entry of the player sample, servers may omit or anonymise the sample
+type Player struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// JavaClient queries a Java Edition server over TCP
+type JavaClient struct {
+	address string
+	Timeout time.Duration
+}
+
+func NewJava(address string) *JavaClient {
+	return &JavaClient{
+		address: address,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Status performs the Server List Ping handshake supported since 1.7
+func (c *JavaClient) Status() (*Status, error) {
+	host, port, err := splitAddress(c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", c.address, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+
+	// Handshake followed by status request
+	handshake := new(bytes.Buffer)
+	writeVarInt(handshake, handshakePacket)
+	writeVarInt(handshake, anyProtocolVersion)
+	writeString(handshake, host)
+	handshake.Write([]byte{byte(port >> 8), byte(port)})
+	writeVarInt(handshake, statusNextState)
+
+	req := new(bytes.Buffer)
+	writePacket(req, handshake.Bytes())
+	writePacket(req, []byte{statusRequestPacket})
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// Response is a single packet holding the status as JSON
+	r := bufio.NewReader(conn)
+	if _, err := readVarInt(r); err != nil {
+		return nil, err
+	}
+	if id, err := readVarInt(r); err != nil {
+		return nil, err
+	} else if id != statusRequestPacket {
+		return nil, fmt.Errorf("unexpected packet id: [0x%x]", id)
+	}
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	} else if length < 0 || length > maxStatusLength {
+		return nil, fmt.Errorf("invalid status length: [%d]", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	status := new(Status)
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, fmt.Errorf("invalid status response: %w", err)
+	}
+	return status, nil
+}
+
+func splitAddress(address string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: [%s]", portStr)
+	}
+	return host, uint16(port), nil
+}
+
+// writePacket writes the data prefixed by its length
+func writePacket(w *bytes.Buffer, data []byte) {
+	writeVarInt(w, int32(len(data)))
+	w.Write(data)
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	writeVarInt(w, int32(len(s)))
+	w.WriteString(s)
+}
+
+func writeVarInt(w *bytes.Buffer, v int32) {
+	u := uint32(v)
+	for {
+		if u&^0x7F == 0 {
+			w.WriteByte(byte(u))
+			return
+		}
+		w.WriteByte(byte(u&0x7F | 0x80))
+		u >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var v uint32
+	for i := 0; i < maxVarIntBytes; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(v), nil
+		}
+	}
+	return 0, errors.New("varint is too big")
+}
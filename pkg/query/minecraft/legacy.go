@@ -0,0 +1,111 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	legacyPingPacket       = 0xFE
+	legacyPingPayload      = 0x01
+	legacyPluginPacket     = 0xFA
+	legacyDisconnectPacket = 0xFF
+	legacyPingChannel      = "MC|PingHost"
+
+	// Protocol version of 1.6.4
+	legacyProtocolVersion = 78
+
+	// Response fields follow this prefix, separated by null characters
+	legacyResponsePrefix = "§1"
+	legacyResponseFields = 6
+)
+
+// LegacyStatus performs the ping used before 1.7, for servers not supporting the current handshake
+func (c *JavaClient) LegacyStatus() (*Status, error) {
+	host, port, err := splitAddress(c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", c.address, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+
+	// Ping followed by the plugin message carrying the host being pinged
+	hostChars := utf16.Encode([]rune(host))
+	req := new(bytes.Buffer)
+	req.Write([]byte{legacyPingPacket, legacyPingPayload, legacyPluginPacket})
+	writeLegacyString(req, legacyPingChannel)
+	binary.Write(req, binary.BigEndian, uint16(7+2*len(hostChars)))
+	req.WriteByte(legacyProtocolVersion)
+	writeLegacyString(req, host)
+	binary.Write(req, binary.BigEndian, int32(port))
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// Response is a kick packet with the status as its reason
+	var header struct {
+		ID     byte
+		Length uint16
+	}
+	if err := binary.Read(conn, binary.BigEndian, &header); err != nil {
+		return nil, err
+	} else if header.ID != legacyDisconnectPacket {
+		return nil, fmt.Errorf("unexpected packet id: [0x%x]", header.ID)
+	}
+	chars := make([]uint16, header.Length)
+	if err := binary.Read(conn, binary.BigEndian, chars); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return parseLegacyStatus(string(utf16.Decode(chars)))
+}
+
+func parseLegacyStatus(resp string) (*Status, error) {
+	fields := strings.Split(resp, "\x00")
+	if len(fields) != legacyResponseFields || fields[0] != legacyResponsePrefix {
+		return nil, errors.New("invalid legacy status response")
+	}
+
+	status := new(Status)
+	var err error
+	if status.Version.Protocol, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid protocol version: [%s]", fields[1])
+	}
+	status.Version.Name = fields[2]
+	if status.Description, err = json.Marshal(fields[3]); err != nil {
+		return nil, err
+	}
+	if status.Players.Online, err = strconv.Atoi(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid player count: [%s]", fields[4])
+	}
+	if status.Players.Max, err = strconv.Atoi(fields[5]); err != nil {
+		return nil, fmt.Errorf("invalid max players: [%s]", fields[5])
+	}
+	return status, nil
+}
+
+// writeLegacyString writes a UTF-16BE string prefixed by its length in characters
+func writeLegacyString(w *bytes.Buffer, s string) {
+	chars := utf16.Encode([]rune(s))
+	binary.Write(w, binary.BigEndian, uint16(len(chars)))
+	binary.Write(w, binary.BigEndian, chars)
+}
@@ -0,0 +1,227 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStatusJSON = `{
+	"version": {"name": "1.20.1", "protocol": 763},
+	"players": {"max": 20, "online": 2, "sample": [{"name": "alice", "id": "1"}, {"name": "bob", "id": "2"}]},
+	"description": {"text": "A Minecraft Server"}
+}`
+
+func Test_JavaClient_Status(t *testing.T) {
+	addr := startFakeJavaServer(t)
+
+	status, err := NewJava(addr).Status()
+
+	require.NoError(t, err)
+	assert.Equal(t, "1.20.1", status.Version.Name)
+	assert.Equal(t, 763, status.Version.Protocol)
+	assert.Equal(t, 20, status.Players.Max)
+	assert.Equal(t, 2, status.Players.Online)
+	assert.Equal(t, []Player{{Name: "alice", ID: "1"}, {Name: "bob", ID: "2"}}, status.Players.Sample)
+	assert.JSONEq(t, `{"text": "A Minecraft Server"}`, string(status.Description))
+}
+
+func Test_JavaClient_LegacyStatus(t *testing.T) {
+	addr := startFakeLegacyServer(t)
+
+	status, err := NewJava(addr).LegacyStatus()
+
+	require.NoError(t, err)
+	assert.Equal(t, "1.6.4", status.Version.Name)
+	assert.Equal(t, 78, status.Version.Protocol)
+	assert.Equal(t, 20, status.Players.Max)
+	assert.Equal(t, 3, status.Players.Online)
+	assert.JSONEq(t, `"A Legacy Server"`, string(status.Description))
+}
+
+func Test_JavaClient_Timeout(t *testing.T) {
+	// Listener that accepts but never responds
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	c := NewJava(l.Addr().String())
+	c.Timeout = 50 * time.Millisecond
+	_, err = c.Status()
+
+	require.Error(t, err)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
+
+func Test_BedrockClient_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverID  string
+		expStatus *BedrockStatus
+		expErr    bool
+	}{
+		{
+			name:     "Happy path",
+			serverID: "MCPE;Bedrock Server;594;1.20.0;1;10;12345;Bedrock level;Survival;1;19132;19133;",
+			expStatus: &BedrockStatus{
+				Edition:    "MCPE",
+				MOTD:       "Bedrock Server",
+				Protocol:   594,
+				Version:    "1.20.0",
+				Players:    1,
+				MaxPlayers: 10,
+				ServerID:   "12345",
+				LevelName:  "Bedrock level",
+				GameMode:   "Survival",
+			},
+		},
+		{
+			name:     "Happy path - Minimal server ID",
+			serverID: "MCPE;Bedrock Server;594;1.20.0;0;10",
+			expStatus: &BedrockStatus{
+				Edition:    "MCPE",
+				MOTD:       "Bedrock Server",
+				Protocol:   594,
+				Version:    "1.20.0",
+				MaxPlayers: 10,
+			},
+		},
+		{
+			name:     "Sad path - Invalid server ID",
+			serverID: "MCPE;Bedrock Server",
+			expErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startFakeBedrockServer(t, tt.serverID)
+
+			status, err := NewBedrock(addr).Status()
+
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expStatus, status)
+		})
+	}
+}
+
+func Test_VarInt(t *testing.T) {
+	for _, v := range []int32{0, 1, 127, 128, 25565, 2147483647, -1} {
+		buf := new(bytes.Buffer)
+		writeVarInt(buf, v)
+
+		got, err := readVarInt(buf)
+
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+// startFakeJavaServer answers a single Server List Ping handshake per connection
+func startFakeJavaServer(t *testing.T) string {
+	return startFakeTCPServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		// Handshake then status request
+		for _, expNextState := range []bool{true, false} {
+			length, err := readVarInt(r)
+			if err != nil {
+				return
+			}
+			packet := make([]byte, length)
+			if _, err := io.ReadFull(r, packet); err != nil {
+				return
+			}
+			if expNextState && packet[len(packet)-1] != statusNextState {
+				return
+			}
+		}
+
+		body := new(bytes.Buffer)
+		writeVarInt(body, statusRequestPacket)
+		writeString(body, testStatusJSON)
+		resp := new(bytes.Buffer)
+		writePacket(resp, body.Bytes())
+		conn.Write(resp.Bytes())
+	})
+}
+
+// startFakeLegacyServer answers a 1.6 ping with a kick packet
+func startFakeLegacyServer(t *testing.T) string {
+	return startFakeTCPServer(t, func(conn net.Conn) {
+		header := make([]byte, 3)
+		if _, err := io.ReadFull(conn, header); err != nil || header[0] != legacyPingPacket {
+			return
+		}
+
+		chars := utf16.Encode([]rune("§1\x0078\x001.6.4\x00A Legacy Server\x003\x0020"))
+		resp := new(bytes.Buffer)
+		resp.WriteByte(legacyDisconnectPacket)
+		binary.Write(resp, binary.BigEndian, uint16(len(chars)))
+		binary.Write(resp, binary.BigEndian, chars)
+		conn.Write(resp.Bytes())
+	})
+}
+
+func startFakeTCPServer(t *testing.T, handle func(conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			handle(conn)
+			conn.Close()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// startFakeBedrockServer answers unconnected pings with the given server ID
+func startFakeBedrockServer(t *testing.T, serverID string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPongSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 25 || buf[0] != unconnectedPingPacket || !bytes.Equal(buf[9:25], raknetMagic) {
+				continue
+			}
+
+			resp := new(bytes.Buffer)
+			resp.WriteByte(unconnectedPongPacket)
+			resp.Write(buf[1:9])
+			binary.Write(resp, binary.BigEndian, int64(42))
+			resp.Write(raknetMagic)
+			binary.Write(resp, binary.BigEndian, uint16(len(serverID)))
+			resp.WriteString(serverID)
+			conn.WriteTo(resp.Bytes(), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
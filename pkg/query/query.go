@@ -0,0 +1,132 @@
+package query
+
+import (
+	"fmt"
+
+	"game-server/pkg/query/a2s"
+	"game-server/pkg/query/minecraft"
+)
+
+const (
+	ProtocolA2S             = "a2s"
+	ProtocolMinecraft       = "minecraft"
+	ProtocolMinecraftLegacy = "minecraft-legacy"
+	ProtocolBedrock         = "bedrock"
+)
+
+// Protocols lists every supported query protocol
+var Protocols = []string{ProtocolA2S, ProtocolMinecraft, ProtocolMinecraftLegacy, ProtocolBedrock}
+
+// Status is a protocol independent summary of the players on a game server
+type Status struct {
+	Players     int
+	MaxPlayers  int
+	PlayerNames []string
+}
+
+// Querier gets the current status of a game server
+type Querier interface {
+	Query() (*Status, error)
+}
+
+// New gets a querier for the given protocol and server address
+func New(protocol string, address string) (Querier, error) {
+	switch protocol {
+	case ProtocolA2S:
+		return &a2sQuerier{client: a2s.New(address)}, nil
+	case ProtocolMinecraft:
+		return &javaQuerier{client: minecraft.NewJava(address)}, nil
+	case ProtocolMinecraftLegacy:
+		return &javaQuerier{client: minecraft.NewJava(address), legacy: true}, nil
+	case ProtocolBedrock:
+		return &bedrockQuerier{client: minecraft.NewBedrock(address)}, nil
+	}
+	return nil, fmt.Errorf("unsupported query protocol: [%s]", protocol)
+}
+
+// IsSupported reports whether the protocol has a querier
+func IsSupported(protocol string) bool {
+	for _, p := range Protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+type a2sQuerier struct {
+	client *a2s.Client
+}
+
+func (q *a2sQuerier) Query() (*Status, error) {
+	info, err := q.client.Info()
+	if err != nil {
+		return nil, err
+	}
+	players, err := q.client.Players()
+	if err != nil {
+		return nil, err
+	}
+	return a2sStatus(info, players), nil
+}
+
+func a2sStatus(info *a2s.Info, players []a2s.Player) *Status {
+	// Bots are counted as players by the server, but never keep it active
+	status := &Status{
+		Players:    int(info.Players) - int(info.Bots),
+		MaxPlayers: int(info.MaxPlayers),
+	}
+	if status.Players < 0 {
+		status.Players = 0
+	}
+	for _, p := range players {
+		// Connecting players are listed without a name
+		if p.Name != "" {
+			status.PlayerNames = append(status.PlayerNames, p.Name)
+		}
+	}
+	return status
+}
+
+type javaQuerier struct {
+	client *minecraft.JavaClient
+	legacy bool
+}
+
+func (q *javaQuerier) Query() (*Status, error) {
+	var mcStatus *minecraft.Status
+	var err error
+	if q.legacy {
+		mcStatus, err = q.client.LegacyStatus()
+	} else {
+		mcStatus, err = q.client.Status()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Player names are limited to the sample the server chooses to share
+	status := &Status{
+		Players:    mcStatus.Players.Online,
+		MaxPlayers: mcStatus.Players.Max,
+	}
+	for _, p := range mcStatus.Players.Sample {
+		status.PlayerNames = append(status.PlayerNames, p.Name)
+	}
+	return status, nil
+}
+
+type bedrockQuerier struct {
+	client *minecraft.BedrockClient
+}
+
+func (q *bedrockQuerier) Query() (*Status, error) {
+	bedrockStatus, err := q.client.Status()
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		Players:    bedrockStatus.Players,
+		MaxPlayers: bedrockStatus.MaxPlayers,
+	}, nil
+}
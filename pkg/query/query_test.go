@@ -0,0 +1,45 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"game-server/pkg/query/a2s"
+)
+
+func Test_a2sStatus(t *testing.T) {
+	players := []a2s.Player{{Name: "alice"}, {Name: ""}}
+
+	tests := []struct {
+		name      string
+		info      *a2s.Info
+		expStatus *Status
+	}{
+		{
+			name:      "Happy path",
+			info:      &a2s.Info{Players: 2, MaxPlayers: 10},
+			expStatus: &Status{Players: 2, MaxPlayers: 10, PlayerNames: []string{"alice"}},
+		},
+		{
+			name:      "Happy path - Bots are not players",
+			info:      &a2s.Info{Players: 5, MaxPlayers: 10, Bots: 3},
+			expStatus: &Status{Players: 2, MaxPlayers: 10, PlayerNames: []string{"alice"}},
+		},
+		{
+			name:      "Happy path - Only bots",
+			info:      &a2s.Info{Players: 4, MaxPlayers: 10, Bots: 4},
+			expStatus: &Status{Players: 0, MaxPlayers: 10, PlayerNames: []string{"alice"}},
+		},
+		{
+			name:      "Sad path - More bots than players",
+			info:      &a2s.Info{Players: 1, MaxPlayers: 10, Bots: 3},
+			expStatus: &Status{Players: 0, MaxPlayers: 10, PlayerNames: []string{"alice"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expStatus, a2sStatus(tt.info, players))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Packet types, execute and auth response share a value
+const (
+	typeResponseValue int32 = 0
+	typeExecCommand   int32 = 2
+	typeAuthResponse  int32 = 2
+	typeAuth          int32 = 3
+
+	// Size of ID and type fields plus the body and empty string terminators
+	headerSize = 10
+
+	// Servers reject request bodies larger than this
+	maxBodySize = 4086
+
+	// Upper bound for a single response packet
+	maxPacketSize = 4096 + headerSize
+
+	// ID returned in an auth response when the password is rejected
+	authFailedID int32 = -1
+)
+
+var DefaultTimeout = 5 * time.Second
+
+// ErrAuthFailed is returned when the server rejects the password
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+// SendError is returned when a command could not be sent, so the server never received it
+type SendError struct {
+	Err error
+}
+
+func (e SendError) Error() string {
+	return fmt.Sprintf("could not send rcon command: %s", e.Err)
+}
+
+func (e SendError) Unwrap() error {
+	return e.Err
+}
+
+// Client is an authenticated connection to a Source RCON server
+type Client struct {
+	conn    net.Conn
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	nextID int32
+}
+
+// Dial connects to the server and authenticates with the password
+func Dial(address string, password string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		Timeout: DefaultTimeout,
+	}
+	if err := c.auth(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Execute runs a command and returns its output, joining responses split over multiple packets,
+// returning a SendError if the command was never sent
+func (c *Client) Execute(command string) (string, error) {
+	if len(command) > maxBodySize {
+		return "", fmt.Errorf("command exceeds [%d] bytes", maxBodySize)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return "", SendError{Err: err}
+	}
+
+	// Follow the command with an empty response packet, which the server mirrors
+	// only once all responses to the command have been sent
+	cmdID, endID := c.id(), c.id()
+	if err := c.write(cmdID, typeExecCommand, command); err != nil {
+		return "", SendError{Err: err}
+	}
+	if err := c.write(endID, typeResponseValue, ""); err != nil {
+		return "", err
+	}
+
+	var resp strings.Builder
+	for {
+		id, _, body, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		switch id {
+		case cmdID:
+			resp.WriteString(body)
+		case endID:
+			return resp.String(), nil
+		}
+		// Anything else is left over from an earlier command
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) auth(password string) error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return err
+	}
+
+	authID := c.id()
+	if err := c.write(authID, typeAuth, password); err != nil {
+		return err
+	}
+
+	// Some servers send an empty response value before the auth response
+	for {
+		id, packetType, _, err := c.read()
+		if err != nil {
+			return err
+		} else if packetType != typeAuthResponse {
+			continue
+		}
+
+		switch id {
+		case authID:
+			return nil
+		case authFailedID:
+			return ErrAuthFailed
+		default:
+			return fmt.Errorf("unexpected auth response id: [%d]", id)
+		}
+	}
+}
+
+func (c *Client) id() int32 {
+	c.nextID++
+	return c.nextID
+}
+
+func (c *Client) write(id int32, packetType int32, body string) error {
+	packet := new(bytes.Buffer)
+	binary.Write(packet, binary.LittleEndian, int32(len(body)+headerSize))
+	binary.Write(packet, binary.LittleEndian, id)
+	binary.Write(packet, binary.LittleEndian, packetType)
+	packet.WriteString(body)
+	packet.Write([]byte{0, 0})
+
+	_, err := c.conn.Write(packet.Bytes())
+	return err
+}
+
+func (c *Client) read() (int32, int32, string, error) {
+	var size int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	} else if size < headerSize || size > maxPacketSize {
+		return 0, 0, "", fmt.Errorf("invalid packet size: [%d]", size)
+	}
+
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, packet); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body := string(bytes.TrimRight(packet[8:], "\x00"))
+	return id, packetType, body, nil
+}
@@ -0,0 +1,105 @@
+package rcon
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "password"
+
+func Test_Client_Execute(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{
+			name:    "Happy path - Single packet",
+			command: "say hello",
+		},
+		{
+			name:    "Happy path - Multiple packets",
+			command: strings.Repeat("a", 3*FakeResponseSize+1),
+		},
+		{
+			name:    "Happy path - Empty response",
+			command: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := StartFakeServer(t, testPassword)
+			c, err := Dial(s.Addr, testPassword)
+			require.NoError(t, err)
+			defer c.Close()
+
+			// Run twice to ensure responses do not bleed into the next command
+			for i := 0; i < 2; i++ {
+				resp, err := c.Execute(tt.command)
+
+				require.NoError(t, err)
+				assert.Equal(t, tt.command, resp)
+			}
+			assert.Equal(t, []string{tt.command, tt.command}, s.Commands())
+		})
+	}
+}
+
+func Test_Dial_AuthFailed(t *testing.T) {
+	s := StartFakeServer(t, testPassword)
+
+	_, err := Dial(s.Addr, "wrong")
+
+	assert.ErrorIs(t, err, ErrAuthFailed)
+}
+
+func Test_Client_Execute_TooLong(t *testing.T) {
+	s := StartFakeServer(t, testPassword)
+	c, err := Dial(s.Addr, testPassword)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Execute(strings.Repeat("a", maxBodySize+1))
+
+	assert.Error(t, err)
+	assert.Empty(t, s.Commands())
+}
+
+func Test_Client_Execute_Failed(t *testing.T) {
+	tests := []struct {
+		name    string
+		closed  bool
+		command string
+		expSent bool
+	}{
+		{
+			name:    "Sad path - Connection dropped after sending",
+			command: FakeDropCommand,
+			expSent: true,
+		},
+		{
+			name:    "Sad path - Connection closed before sending",
+			closed:  true,
+			command: "say hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := StartFakeServer(t, testPassword)
+			c, err := Dial(s.Addr, testPassword)
+			require.NoError(t, err)
+			defer c.Close()
+			if tt.closed {
+				c.Close()
+			}
+
+			_, err = c.Execute(tt.command)
+
+			require.Error(t, err)
+			assert.Equal(t, !tt.expSent, errors.As(err, &SendError{}))
+		})
+	}
+}
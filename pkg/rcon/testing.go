@@ -0,0 +1,113 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Responses from FakeServer are split into packets of this size to exercise reassembly
+	FakeResponseSize = 1000
+
+	// FakeServer closes the connection on receiving this command without responding, as servers do after stopping
+	FakeDropCommand = "drop"
+)
+
+// FakeServer is a local RCON server that echoes commands back, behaving like a Source server
+// around auth and the end of responses
+type FakeServer struct {
+	Addr     string
+	password string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func StartFakeServer(t *testing.T, password string) *FakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	s := &FakeServer{
+		Addr:     l.Addr().String(),
+		password: password,
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Commands gets every command received so far
+func (s *FakeServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *FakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.LittleEndian, &size); err != nil {
+			return
+		}
+		packet := make([]byte, size)
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+		packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+		body := string(packet[8 : size-2])
+
+		switch packetType {
+		case typeAuth:
+			writeFake(conn, id, typeResponseValue, "")
+			if body != s.password {
+				id = authFailedID
+			}
+			writeFake(conn, id, typeAuthResponse, "")
+
+		case typeExecCommand:
+			s.mu.Lock()
+			s.commands = append(s.commands, body)
+			s.mu.Unlock()
+			if body == FakeDropCommand {
+				return
+			}
+
+			for len(body) > FakeResponseSize {
+				writeFake(conn, id, typeResponseValue, body[:FakeResponseSize])
+				body = body[FakeResponseSize:]
+			}
+			writeFake(conn, id, typeResponseValue, body)
+
+		case typeResponseValue:
+			// Mirror the end marker followed by the extra packet sent by Source servers
+			writeFake(conn, id, typeResponseValue, "")
+			writeFake(conn, id, typeResponseValue, "\x00\x01\x00\x00")
+		}
+	}
+}
+
+func writeFake(conn net.Conn, id int32, packetType int32, body string) {
+	b := new(bytes.Buffer)
+	binary.Write(b, binary.LittleEndian, int32(len(body)+headerSize))
+	binary.Write(b, binary.LittleEndian, id)
+	binary.Write(b, binary.LittleEndian, packetType)
+	b.WriteString(body)
+	b.Write([]byte{0, 0})
+	conn.Write(b.Bytes())
+}